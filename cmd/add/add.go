@@ -2,6 +2,7 @@ package add
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -14,40 +15,107 @@ const (
 	clientFlag    = "client"
 	secretFlag    = "secret"
 	overwriteFlag = "overwrite"
+	algorithmFlag = "algorithm"
+	digitsFlag    = "digits"
+	periodFlag    = "period"
+	issuerFlag    = "issuer"
+	accountFlag   = "account"
+	typeFlag      = "type"
+	counterFlag   = "counter"
 )
 
 func Create(cfg *config.Config) *cobra.Command {
 	var (
 		newSecret secret.SecretValue
 		overwrite bool
+		entry     secret.Entry
+		cmd       *cobra.Command
 	)
 
-	cmd := &cobra.Command{
-		Use:   "add CLIENT_ID",
+	cmd = &cobra.Command{
+		Use:   "add [CLIENT_ID]",
 		Short: "Add a client with secret to the vault",
-		Args:  cobra.ExactArgs(1),
+		Long: "Add a client with secret to the vault. If the secret is imported from an otpauth:// QR code " +
+			"the client parameters are taken from it and CLIENT_ID defaults to ISSUER:ACCOUNT.",
+		Args: cobra.RangeArgs(0, 1),
 		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
-			clientId := args[0]
+			newEntry := mergeEntry(cmd, entry, newSecret.Entry())
 
-			return vlt.ModifySecrets(func(secrets map[string]string) error {
-				if secret := secrets[clientId]; secret != "" && !overwrite {
-					return fmt.Errorf("The client ID %s already exists in the vault. Pass --%s option to overwrite with new value.", clientId, overwriteFlag)
-				}
+			var clientId string
+			if len(args) > 0 {
+				clientId = args[0]
+			} else if clientId = newEntry.SuggestedClientID(); clientId == "" {
+				return fmt.Errorf("CLIENT_ID must be provided if the secret doesn't contain the issuer or the account name")
+			}
 
-				newSecretValue, err := newSecret.ReadSecret("TOTP secret: ", "Confirm TOTP secret")
+			existsErr := fmt.Errorf("The client ID %s already exists in the vault. Pass --%s option to overwrite with new value.", clientId, overwriteFlag)
+			if !overwrite {
+				// checked before asking for the secret, the change is refused anyway if the client is added meanwhile
+				clientIDs, err := vlt.ListClients()
 				if err != nil {
 					return err
 				}
+				if i := sort.SearchStrings(clientIDs, clientId); i < len(clientIDs) && clientIDs[i] == clientId {
+					return existsErr
+				}
+			}
 
-				secrets[clientId] = newSecretValue
+			newSecretValue, err := newSecret.ReadSecret("OTP secret: ", "Confirm OTP secret")
+			if err != nil {
+				return err
+			}
+			newEntry.Secret = newSecretValue
 
-				return nil
-			})
+			err = vlt.ApplyChanges(vault.PutEntry(clientId, newEntry, overwrite))
+			if err == vault.ErrClientExists {
+				return existsErr
+			}
+			return err
 		}),
 	}
 
 	cmd.Flags().VarP(&newSecret, secretFlag, "s", "Client secret")
 	cmd.Flags().BoolVar(&overwrite, overwriteFlag, false, "Overwrite existing client ID")
+	cmd.Flags().StringVar(&entry.Type, typeFlag, secret.TypeTOTP, "OTP type (totp or hotp)")
+	cmd.Flags().Uint64Var(&entry.Counter, counterFlag, 0, "Initial counter of an HOTP client")
+	cmd.Flags().StringVar(&entry.Algorithm, algorithmFlag, secret.DefaultAlgorithm, "HMAC algorithm (SHA1, SHA256 or SHA512)")
+	cmd.Flags().IntVar(&entry.Digits, digitsFlag, secret.DefaultDigits, "Number of digits of the generated code")
+	cmd.Flags().UintVar(&entry.Period, periodFlag, secret.DefaultPeriod, "Number of seconds the generated code is valid for")
+	cmd.Flags().StringVar(&entry.Issuer, issuerFlag, "", "Name of the provider the client belongs to")
+	cmd.Flags().StringVar(&entry.Account, accountFlag, "", "Account name of the client")
 
 	return cmd
 }
+
+// mergeEntry returns the entry imported with the secret (if any) with the explicitly passed flags applied on top
+func mergeEntry(cmd *cobra.Command, flagEntry secret.Entry, imported *secret.Entry) secret.Entry {
+	if imported == nil {
+		return flagEntry
+	}
+
+	result := *imported
+	flags := cmd.Flags()
+	if flags.Changed(typeFlag) {
+		result.Type = flagEntry.Type
+	}
+	if flags.Changed(counterFlag) {
+		result.Counter = flagEntry.Counter
+	}
+	if flags.Changed(algorithmFlag) {
+		result.Algorithm = flagEntry.Algorithm
+	}
+	if flags.Changed(digitsFlag) {
+		result.Digits = flagEntry.Digits
+	}
+	if flags.Changed(periodFlag) {
+		result.Period = flagEntry.Period
+	}
+	if flags.Changed(issuerFlag) {
+		result.Issuer = flagEntry.Issuer
+	}
+	if flags.Changed(accountFlag) {
+		result.Account = flagEntry.Account
+	}
+
+	return result
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/audit"
+)
+
+const (
+	sinceFlag   = "since"
+	summaryFlag = "summary"
+	jsonFlag    = "json"
+
+	timeFormat = "2006-01-02 15:04:05"
+)
+
+func Create(cfg *config.Config) *cobra.Command {
+	var (
+		since      time.Duration
+		summary    bool
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "audit [CLIENT_ID_PATTERN...]",
+		Short: "Show the requests served by the cache server",
+		Long: "Show the requests served by the cache server, as recorded in the audit log. " +
+			"If CLIENT_ID_PATTERNs (shell patterns, e.g. 'aws-*') are passed, only the requests for the matching clients are shown.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.AuditLog == "" {
+				return fmt.Errorf("The audit log is disabled")
+			}
+
+			var from time.Time
+			if since > 0 {
+				from = time.Now().Add(-since)
+			}
+			events, err := audit.Read(cfg.AuditLog, from)
+			if err != nil {
+				return err
+			}
+			events, err = filterEvents(events, args)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case jsonOutput:
+				encoder := json.NewEncoder(os.Stdout)
+				for _, event := range events {
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+				}
+				return nil
+			case summary:
+				return printSummary(events)
+			default:
+				return printEvents(events)
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&since, sinceFlag, 24*time.Hour, "show the requests served within this time (0 for all)")
+	cmd.Flags().BoolVar(&summary, summaryFlag, false, "show how many times each client has been used by each executable")
+	cmd.Flags().BoolVar(&jsonOutput, jsonFlag, false, "print the events as JSON lines")
+
+	return cmd
+}
+
+// filterEvents keeps the events of the clients matching any of the patterns, either the source or the target
+// of a rename. The events without a client ID (e.g. reading all the secrets) are always kept.
+func filterEvents(events []audit.Event, patterns []string) ([]audit.Event, error) {
+	if len(patterns) == 0 {
+		return events, nil
+	}
+
+	var result []audit.Event
+	for _, event := range events {
+		if event.ClientID == "" {
+			result = append(result, event)
+			continue
+		}
+		for _, pattern := range patterns {
+			ok, err := path.Match(pattern, event.ClientID)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid client ID pattern %s: %s", pattern, err.Error())
+			}
+			if !ok && event.NewClientID != "" {
+				ok, _ = path.Match(pattern, event.NewClientID)
+			}
+			if ok {
+				result = append(result, event)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func printEvents(events []audit.Event) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tCLIENT ID\tPID\tEXECUTABLE\tOUTCOME")
+	for _, event := range events {
+		outcome := event.Outcome
+		if event.Error != "" {
+			outcome += ": " + event.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", event.Time.Local().Format(timeFormat), event.Action,
+			clientString(event), pidString(event.PID), orDash(event.Executable), outcome)
+	}
+	return w.Flush()
+}
+
+type summaryKey struct {
+	clientID   string
+	action     string
+	executable string
+}
+
+type summaryValue struct {
+	count    int
+	failed   int
+	lastUsed time.Time
+}
+
+func printSummary(events []audit.Event) error {
+	summaries := make(map[summaryKey]*summaryValue)
+	for _, event := range events {
+		key := summaryKey{clientID: clientString(event), action: event.Action, executable: event.Executable}
+		value, ok := summaries[key]
+		if !ok {
+			value = &summaryValue{}
+			summaries[key] = value
+		}
+		value.count++
+		if event.Outcome != audit.OutcomeOK {
+			value.failed++
+		}
+		if event.Time.After(value.lastUsed) {
+			value.lastUsed = event.Time
+		}
+	}
+
+	keys := make([]summaryKey, 0, len(summaries))
+	for key := range summaries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].clientID != keys[j].clientID {
+			return keys[i].clientID < keys[j].clientID
+		}
+		if keys[i].action != keys[j].action {
+			return keys[i].action < keys[j].action
+		}
+		return keys[i].executable < keys[j].executable
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT ID\tACTION\tEXECUTABLE\tCOUNT\tFAILED\tLAST USED")
+	for _, key := range keys {
+		value := summaries[key]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", key.clientID, key.action, orDash(key.executable),
+			value.count, value.failed, value.lastUsed.Local().Format(timeFormat))
+	}
+	return w.Flush()
+}
+
+// clientString shows the client ID of the event, including the target of a rename
+func clientString(event audit.Event) string {
+	if event.NewClientID != "" {
+		return orDash(event.ClientID) + " -> " + event.NewClientID
+	}
+	return orDash(event.ClientID)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func pidString(pid int) string {
+	if pid == 0 {
+		return "-"
+	}
+	return fmt.Sprint(pid)
+}
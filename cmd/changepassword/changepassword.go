@@ -0,0 +1,19 @@
+package changepassword
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+func Create(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "change-password",
+		Short: "Change the vault password and re-encrypt the vault",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return vault.ChangePassword(cfg)
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/codec"
+	"github.com/nordcloud/mfacli/pkg/exporter"
+	"github.com/nordcloud/mfacli/pkg/secret"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+const (
+	formatFlag     = "format"
+	outputFlag     = "output"
+	passphraseFlag = "passphrase"
+	forceFlag      = "force"
+)
+
+func Create(cfg *config.Config) *cobra.Command {
+	var (
+		format     string
+		output     string
+		passphrase secret.SecretValue
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [CLIENT_ID_PATTERN...]",
+		Short: "Export the clients stored in the vault (e.g. for backup purposes)",
+		Long: "Export the clients stored in the vault (e.g. for backup purposes). The vault format is encrypted with a " +
+			"separate passphrase and can be used with the --vault flag directly, the uri and aegis formats are NOT encrypted. " +
+			"If CLIENT_ID_PATTERNs (shell patterns, e.g. 'aws-*') are passed, only the matching clients are exported.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := exporter.CheckFormat(format); err != nil {
+				return err
+			}
+			if !force && output == "" && terminal.IsTerminal(int(os.Stdout.Fd())) {
+				if exporter.IsPlaintext(format) {
+					return fmt.Errorf("Refusing to write unencrypted secrets to the terminal. Pass --%s or --%s option to do it anyway.", outputFlag, forceFlag)
+				}
+				if exporter.IsBinary(format) {
+					return fmt.Errorf("Refusing to write the binary %s format to the terminal. Pass --%s or --%s option to do it anyway.", format, outputFlag, forceFlag)
+				}
+			}
+
+			newCfg := *cfg
+			newCfg.NoCache = true // always ask for password for this action
+			vlt, err := vault.Open(&newCfg)
+			if err != nil {
+				return err
+			}
+
+			secrets, err := vlt.GetSecrets()
+			if err != nil {
+				return err
+			}
+			secrets, err = filterSecrets(secrets, args)
+			if err != nil {
+				return err
+			}
+
+			data, err := exporter.Export(format, secrets, exporter.Options{
+				Key: func() (*codec.Key, error) {
+					pwd, err := passphrase.ReadSecret("Backup passphrase: ", "Confirm backup passphrase: ")
+					if err != nil {
+						return nil, err
+					}
+					return vault.NewEncKey(cfg, pwd)
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := writeOutput(output, data, force); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d client(s)\n", len(secrets))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, formatFlag, exporter.FormatVault, "export format ("+strings.Join(exporter.Formats(), ", ")+")")
+	cmd.Flags().StringVarP(&output, outputFlag, "o", "", "output file (stdout if not set)")
+	cmd.Flags().Var(&passphrase, passphraseFlag, "passphrase of the encrypted backup in a format accepted by openssl (env:*, file:* or pass:*)")
+	cmd.Flags().BoolVar(&force, forceFlag, false, "write unencrypted secrets or binary data to the terminal and overwrite an existing output file")
+
+	return cmd
+}
+
+func filterSecrets(secrets map[string]secret.Entry, patterns []string) (map[string]secret.Entry, error) {
+	if len(patterns) == 0 {
+		return secrets, nil
+	}
+
+	result := make(map[string]secret.Entry)
+	for _, pattern := range patterns {
+		matched := false
+		for clientID, entry := range secrets {
+			ok, err := path.Match(pattern, clientID)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid client ID pattern %s: %s", pattern, err.Error())
+			}
+			if ok {
+				result[clientID] = entry
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("No client ID matches %s", pattern)
+		}
+	}
+
+	return result, nil
+}
+
+func writeOutput(output string, data []byte, force bool) error {
+	if output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(output, flags, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
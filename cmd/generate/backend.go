@@ -0,0 +1,115 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	backendFlag = "backend"
+	backendAuto = "auto"
+
+	wtypeCmd   = "wtype"
+	ydotoolCmd = "ydotool"
+)
+
+type typeFn func(code string, newLine bool) error
+
+var (
+	typeBackends = map[string]typeFn{
+		xdotoolCmd: typeWithXdotool,
+		wtypeCmd:   typeWithWtype,
+		ydotoolCmd: typeWithYdotool,
+	}
+)
+
+// selectBackendOnPreRun selects the backend of the command before the code is generated, so that an invalid
+// --backend value doesn't waste an HOTP counter
+func selectBackendOnPreRun(cmd *cobra.Command, selectFn func() error) {
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return selectFn()
+	}
+}
+
+// isWayland tells whether the command runs in a Wayland session. XWayland sets DISPLAY as well,
+// but the X11 tools cannot reach the native Wayland windows, so WAYLAND_DISPLAY takes precedence.
+func isWayland() bool {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return true
+	}
+	return os.Getenv("DISPLAY") == "" && os.Getenv("XDG_SESSION_TYPE") == "wayland"
+}
+
+// detectTypeBackend prefers wtype on Wayland, unless only ydotool (which works with any compositor) is installed
+func detectTypeBackend() string {
+	if !isWayland() {
+		return xdotoolCmd
+	}
+	if _, err := exec.LookPath(wtypeCmd); err != nil {
+		if _, err := exec.LookPath(ydotoolCmd); err == nil {
+			return ydotoolCmd
+		}
+	}
+	return wtypeCmd
+}
+
+func typeBackend(name string) (typeFn, error) {
+	if name == backendAuto {
+		name = detectTypeBackend()
+	}
+
+	fn, ok := typeBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported --%s value: %s (supported: %s)", backendFlag, name, strings.Join(typeBackendNames(), ", "))
+	}
+	log.WithField("backend", name).Debug("Selected typing backend")
+	return fn, nil
+}
+
+func typeBackendNames() []string {
+	names := make([]string, 0, len(typeBackends))
+	for name := range typeBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeWithXdotool(code string, newLine bool) error {
+	if err := run(xdotoolCmd, "type", "--clearmodifiers", code); err != nil {
+		return err
+	}
+	if newLine {
+		return run(xdotoolCmd, "key", "--clearmodifiers", "Return")
+	}
+	return nil
+}
+
+func typeWithWtype(code string, newLine bool) error {
+	args := []string{code}
+	if newLine {
+		args = append(args, "-k", "Return")
+	}
+	return run(wtypeCmd, args...)
+}
+
+// typeWithYdotool types the newline as a part of the text, as the key codes differ between ydotool versions
+func typeWithYdotool(code string, newLine bool) error {
+	if newLine {
+		code += "\n"
+	}
+	return run(ydotoolCmd, "type", code)
+}
+
+func run(name string, args ...string) error {
+	log.WithField("args", args).Debug("Running " + name)
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,108 @@
+package generate
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+)
+
+// clearRequest is passed to the helper process clearing the clipboard on its standard input,
+// so that neither the code nor the previous clipboard content show up in the process list
+type clearRequest struct {
+	Backend string            `json:"backend"`
+	At      time.Time         `json:"at"`
+	Text    string            `json:"text"`
+	Targets []string          `json:"targets"`
+	Restore map[string]string `json:"restore"`
+}
+
+// CreateClearClipboardCmd creates the hidden command run in the background by the clipboard command
+func CreateClearClipboardCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:    config.InternalClearClipboardCmd,
+		Hidden: true,
+		Args:   cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var request clearRequest
+			if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+				return err
+			}
+			cb, err := selectClipboard(request.Backend)
+			if err != nil {
+				return err
+			}
+
+			time.Sleep(time.Until(request.At))
+
+			for _, target := range request.Targets {
+				current, err := cb.Paste(target)
+				if err != nil {
+					return err
+				}
+				// the clipboard has been used for something else meanwhile
+				if !cb.SameContent(current, request.Text) {
+					log.WithField("target", target).Debug("Clipboard content has changed, leaving it")
+					continue
+				}
+
+				if err := cb.Copy(target, request.Restore[target]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// scheduleClear saves the content of the targets supported by the clipboard and starts the helper process
+// restoring it at the given time, if the targets still contain the text
+func scheduleClear(cb clipboard, targets []string, text string, at time.Time) (func() error, error) {
+	request := clearRequest{
+		Backend: cb.Name(),
+		At:      at,
+		Text:    text,
+		Restore: make(map[string]string),
+	}
+	for _, target := range targets {
+		if !cb.Supports(target) {
+			continue
+		}
+		previous, err := cb.Paste(target)
+		if err != nil {
+			return nil, err
+		}
+		request.Targets = append(request.Targets, target)
+		request.Restore[target] = previous
+	}
+
+	// the helper is started only once the text has been copied
+	return func() error {
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.Command(executable, config.InternalClearClipboardCmd)
+		// the helper must outlive the terminal the command has been run from
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		if err := json.NewEncoder(pipe).Encode(request); err != nil {
+			return err
+		}
+		return pipe.Close()
+	}, nil
+}
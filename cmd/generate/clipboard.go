@@ -0,0 +1,217 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nordcloud/mfacli/config"
+)
+
+const (
+	targetClipboard = "clipboard"
+	targetPrimary   = "primary"
+	targetSecondary = "secondary"
+
+	xselCmd   = "xsel"
+	xclipCmd  = "xclip"
+	wlCopyCmd = "wl-copy"
+	pbcopyCmd = "pbcopy"
+
+	wlPasteCmd = "wl-paste"
+	pbpasteCmd = "pbpaste"
+)
+
+type clipboard interface {
+	Name() string
+	// Supports tells whether the target selection (clipboard, primary or secondary) exists
+	Supports(target string) bool
+	Copy(target, text string) error
+	Paste(target string) (string, error)
+	// SameContent tells whether the pasted content is the copied text, as some tools don't paste the text
+	// exactly as it has been copied
+	SameContent(pasted, text string) bool
+}
+
+// commandClipboard copies the text by writing it to the standard input of a command, and pastes it
+// by reading the output of another one
+type commandClipboard struct {
+	name string
+	// targetArgs maps the supported targets to the arguments of the command
+	targetArgs map[string][]string
+
+	pasteName string
+	pasteArgs map[string][]string
+	// pasteTrimsNewline is set when the trailing newline of the text is missing from the pasted content
+	pasteTrimsNewline bool
+}
+
+var (
+	clipboards = map[string]clipboard{
+		xselCmd: &commandClipboard{
+			name: xselCmd,
+			targetArgs: map[string][]string{
+				targetClipboard: {"--input", "--clipboard"},
+				targetPrimary:   {"--input", "--primary"},
+				targetSecondary: {"--input", "--secondary"},
+			},
+			pasteName: xselCmd,
+			pasteArgs: map[string][]string{
+				targetClipboard: {"--output", "--clipboard"},
+				targetPrimary:   {"--output", "--primary"},
+				targetSecondary: {"--output", "--secondary"},
+			},
+		},
+		xclipCmd: &commandClipboard{
+			name: xclipCmd,
+			targetArgs: map[string][]string{
+				targetClipboard: {"-in", "-selection", "clipboard"},
+				targetPrimary:   {"-in", "-selection", "primary"},
+				targetSecondary: {"-in", "-selection", "secondary"},
+			},
+			pasteName: xclipCmd,
+			pasteArgs: map[string][]string{
+				targetClipboard: {"-out", "-selection", "clipboard"},
+				targetPrimary:   {"-out", "-selection", "primary"},
+				targetSecondary: {"-out", "-selection", "secondary"},
+			},
+		},
+		// there is no secondary selection on Wayland
+		wlCopyCmd: &commandClipboard{
+			name: wlCopyCmd,
+			targetArgs: map[string][]string{
+				targetClipboard: {},
+				targetPrimary:   {"--primary"},
+			},
+			pasteName: wlPasteCmd,
+			pasteArgs: map[string][]string{
+				targetClipboard: {"--no-newline"},
+				targetPrimary:   {"--no-newline", "--primary"},
+			},
+			pasteTrimsNewline: true,
+		},
+		// macOS has a single general pasteboard
+		pbcopyCmd: &commandClipboard{
+			name: pbcopyCmd,
+			targetArgs: map[string][]string{
+				targetClipboard: {},
+			},
+			pasteName: pbpasteCmd,
+			pasteArgs: map[string][]string{
+				targetClipboard: {},
+			},
+		},
+	}
+)
+
+func (c *commandClipboard) Name() string {
+	return c.name
+}
+
+func (c *commandClipboard) Supports(target string) bool {
+	_, ok := c.targetArgs[target]
+	return ok
+}
+
+func (c *commandClipboard) Copy(target, text string) error {
+	args, ok := c.targetArgs[target]
+	if !ok {
+		return fmt.Errorf("%s doesn't support the %s target", c.name, target)
+	}
+
+	log.WithField("args", args).Debug("Running " + c.name)
+	cmd := exec.Command(c.name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	// the tools serving the selection keep running in the background, they must not hold
+	// a pipe of this process open
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Paste returns the content of the target, an empty selection is reported as an empty string
+// rather than as an error as it's not distinguishable by all the tools
+func (c *commandClipboard) Paste(target string) (string, error) {
+	args, ok := c.pasteArgs[target]
+	if !ok {
+		return "", fmt.Errorf("%s doesn't support the %s target", c.pasteName, target)
+	}
+
+	log.WithField("args", args).Debug("Running " + c.pasteName)
+	output, err := exec.Command(c.pasteName, args...).Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		log.WithError(err).WithField("target", target).Debug("Failed to paste, assuming the selection is empty")
+		return "", nil
+	}
+	return string(output), err
+}
+
+func (c *commandClipboard) SameContent(pasted, text string) bool {
+	if c.pasteTrimsNewline {
+		return strings.TrimSuffix(pasted, "\n") == strings.TrimSuffix(text, "\n")
+	}
+	return pasted == text
+}
+
+// copyToClipboard copies the text to all the targets supported by the clipboard, it fails only if none of them is
+func copyToClipboard(cb clipboard, targets []string, text string) error {
+	copied := false
+	for _, target := range targets {
+		if !cb.Supports(target) {
+			log.WithField("target", target).Debug("Target not supported by " + cb.Name() + ", skipping")
+			continue
+		}
+		if err := cb.Copy(target, text); err != nil {
+			return err
+		}
+		copied = true
+	}
+
+	if !copied {
+		return fmt.Errorf("None of the targets %s is supported by %s", strings.Join(targets, ", "), cb.Name())
+	}
+	return nil
+}
+
+// detectClipboard picks the tool according to the platform and the session, preferring
+// xsel to xclip in X11 sessions unless only xclip is installed
+func detectClipboard() string {
+	if runtime.GOOS == config.DarwinGOOS {
+		return pbcopyCmd
+	}
+	if isWayland() {
+		return wlCopyCmd
+	}
+	if _, err := exec.LookPath(xselCmd); err != nil {
+		if _, err := exec.LookPath(xclipCmd); err == nil {
+			return xclipCmd
+		}
+	}
+	return xselCmd
+}
+
+func selectClipboard(name string) (clipboard, error) {
+	if name == backendAuto {
+		name = detectClipboard()
+	}
+
+	cb, ok := clipboards[name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported --%s value: %s (supported: %s)", backendFlag, name, strings.Join(clipboardNames(), ", "))
+	}
+	log.WithField("backend", name).Debug("Selected clipboard backend")
+	return cb, nil
+}
+
+func clipboardNames() []string {
+	names := make([]string, 0, len(clipboards))
+	for name := range clipboards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
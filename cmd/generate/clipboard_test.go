@@ -0,0 +1,241 @@
+package generate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeScript records the arguments and the standard input of every run, and prints the content
+// of the <name>.out file if it exists
+const fakeScript = `#!/bin/sh
+name=$(basename "$0")
+for arg in "$@"; do printf '%s\n' "$arg"; done > "$FAKE_DIR/$name.args"
+cat > "$FAKE_DIR/$name.stdin"
+echo "$name" >> "$FAKE_DIR/calls"
+if [ -f "$FAKE_DIR/$name.out" ]; then cat "$FAKE_DIR/$name.out"; fi
+`
+
+// setupFakeTools puts fake clipboard tools on the PATH and returns the directory they record their runs to
+func setupFakeTools(t *testing.T) string {
+	dir := t.TempDir()
+	for _, name := range []string{xselCmd, xclipCmd, wlCopyCmd, wlPasteCmd, pbcopyCmd, pbpasteCmd} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(fakeScript), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the fake tools shadow the real ones, the rest of the PATH is kept for the shell utilities
+	setenv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	setenv(t, "FAKE_DIR", dir)
+	return dir
+}
+
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func readRecorded(t *testing.T, dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func recordedArgs(t *testing.T, dir, name string) []string {
+	args := strings.Split(readRecorded(t, dir, name+".args"), "\n")
+	return args[:len(args)-1]
+}
+
+func recordedCalls(t *testing.T, dir string) []string {
+	calls := strings.Fields(readRecorded(t, dir, "calls"))
+	os.Remove(filepath.Join(dir, "calls"))
+	return calls
+}
+
+func TestCommandClipboardCopy(t *testing.T) {
+	tests := []struct {
+		backend string
+		args    map[string][]string
+	}{
+		{
+			backend: xselCmd,
+			args: map[string][]string{
+				targetClipboard: {"--input", "--clipboard"},
+				targetPrimary:   {"--input", "--primary"},
+				targetSecondary: {"--input", "--secondary"},
+			},
+		},
+		{
+			backend: xclipCmd,
+			args: map[string][]string{
+				targetClipboard: {"-in", "-selection", "clipboard"},
+				targetPrimary:   {"-in", "-selection", "primary"},
+				targetSecondary: {"-in", "-selection", "secondary"},
+			},
+		},
+		{
+			backend: wlCopyCmd,
+			args: map[string][]string{
+				targetClipboard: {},
+				targetPrimary:   {"--primary"},
+			},
+		},
+		{
+			backend: pbcopyCmd,
+			args: map[string][]string{
+				targetClipboard: {},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.backend, func(t *testing.T) {
+			dir := setupFakeTools(t)
+			cb, err := selectClipboard(test.backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, target := range []string{targetClipboard, targetPrimary, targetSecondary} {
+				expected, ok := test.args[target]
+				if cb.Supports(target) != ok {
+					t.Errorf("Supports(%q) = %v, want %v", target, !ok, ok)
+				}
+
+				err := cb.Copy(target, "123456\n")
+				if !ok {
+					if err == nil {
+						t.Errorf("Copy(%q) succeeded, want an error for an unsupported target", target)
+					}
+					if calls := recordedCalls(t, dir); len(calls) != 0 {
+						t.Errorf("Copy(%q) ran %v for an unsupported target", target, calls)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Copy(%q) failed: %v", target, err)
+				}
+
+				if calls := recordedCalls(t, dir); !reflect.DeepEqual(calls, []string{test.backend}) {
+					t.Errorf("Copy(%q) ran %v, want %s once", target, calls, test.backend)
+				}
+				if args := recordedArgs(t, dir, test.backend); !reflect.DeepEqual(args, expected) {
+					t.Errorf("Copy(%q) arguments = %q, want %q", target, args, expected)
+				}
+				// the code is passed on the standard input exactly once, never as an argument
+				if stdin := readRecorded(t, dir, test.backend+".stdin"); stdin != "123456\n" {
+					t.Errorf("Copy(%q) standard input = %q, want %q", target, stdin, "123456\n")
+				}
+			}
+		})
+	}
+}
+
+func TestCopyToClipboardSkipsUnsupportedTargets(t *testing.T) {
+	dir := setupFakeTools(t)
+
+	cb, err := selectClipboard(pbcopyCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyToClipboard(cb, []string{targetPrimary, targetClipboard, targetSecondary}, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := recordedCalls(t, dir); !reflect.DeepEqual(calls, []string{pbcopyCmd}) {
+		t.Errorf("copyToClipboard() ran %v, want %s once", calls, pbcopyCmd)
+	}
+	if args := recordedArgs(t, dir, pbcopyCmd); len(args) != 0 {
+		t.Errorf("%s arguments = %q, want none", pbcopyCmd, args)
+	}
+
+	cb, err = selectClipboard(wlCopyCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyToClipboard(cb, []string{targetSecondary}, "123456"); err == nil {
+		t.Error("copyToClipboard() succeeded with no supported target, want an error")
+	}
+	if calls := recordedCalls(t, dir); len(calls) != 0 {
+		t.Errorf("copyToClipboard() ran %v with no supported target", calls)
+	}
+}
+
+func TestCommandClipboardPaste(t *testing.T) {
+	tests := []struct {
+		backend string
+		tool    string
+		args    []string
+	}{
+		{backend: xselCmd, tool: xselCmd, args: []string{"--output", "--primary"}},
+		{backend: xclipCmd, tool: xclipCmd, args: []string{"-out", "-selection", "primary"}},
+		{backend: wlCopyCmd, tool: wlPasteCmd, args: []string{"--no-newline", "--primary"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.backend, func(t *testing.T) {
+			dir := setupFakeTools(t)
+			if err := ioutil.WriteFile(filepath.Join(dir, test.tool+".out"), []byte("previous"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cb, err := selectClipboard(test.backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pasted, err := cb.Paste(targetPrimary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pasted != "previous" {
+				t.Errorf("Paste() = %q, want %q", pasted, "previous")
+			}
+			if args := recordedArgs(t, dir, test.tool); !reflect.DeepEqual(args, test.args) {
+				t.Errorf("%s arguments = %q, want %q", test.tool, args, test.args)
+			}
+		})
+	}
+}
+
+func TestCommandClipboardSameContent(t *testing.T) {
+	tests := []struct {
+		backend string
+		pasted  string
+		text    string
+		same    bool
+	}{
+		{backend: xselCmd, pasted: "123456\n", text: "123456\n", same: true},
+		{backend: xselCmd, pasted: "654321\n", text: "123456\n", same: false},
+		// wl-paste --no-newline drops the newline copied with --newline
+		{backend: wlCopyCmd, pasted: "123456", text: "123456\n", same: true},
+		{backend: wlCopyCmd, pasted: "123456", text: "123456", same: true},
+		{backend: wlCopyCmd, pasted: "654321", text: "123456\n", same: false},
+	}
+
+	for _, test := range tests {
+		cb, err := selectClipboard(test.backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := cb.SameContent(test.pasted, test.text); same != test.same {
+			t.Errorf("%s SameContent(%q, %q) = %v, want %v", test.backend, test.pasted, test.text, same, test.same)
+		}
+	}
+}
@@ -2,131 +2,217 @@ package generate
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
-	"runtime"
 	"strings"
 	"time"
 
-	"github.com/pquerna/otp/totp"
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/picker"
+	"github.com/nordcloud/mfacli/pkg/secret"
 	"github.com/nordcloud/mfacli/pkg/vault"
 )
 
 const (
-	newLineFlag = "newline"
+	newLineFlag     = "newline"
+	minValidityFlag = "min-validity"
+	pickFlag        = "pick"
+	launcherFlag    = "launcher"
+
+	// launcherAuto picks the built-in selector in a terminal and detects the launcher otherwise
+	launcherAuto = "auto"
 
 	xdotoolCmd = "xdotool"
 
-	xselCmd            = "xsel"
 	xselTargetsFlag    = "xsel-targets"
-	defaultXselTargets = "primary,clipboard"
+	defaultXselTargets = targetPrimary + "," + targetClipboard
 
-	pbcopyCmd = "pbcopy"
+	clearAfterFlag = "clear-after"
+	noClearFlag    = "no-clear"
 )
 
 func CreateTypeCmd(cfg *config.Config) *cobra.Command {
-	return createGenerateCmd(cfg, "type", "Simulate typing of the TOTP code", func(code string, newLine bool) error {
-		args := []string{"type", "--clearmodifiers", code}
-		log.WithField("args", args).Debug("Running " + xdotoolCmd)
-		cmd := exec.Command(xdotoolCmd, args...)
-		if err := cmd.Run(); err != nil {
-			return err
-		}
-
-		if newLine {
-			args := []string{"key", "--clearmodifiers", "Return"}
-			log.WithField("args", args).Debug("Running " + xdotoolCmd)
-			cmd = exec.Command(xdotoolCmd, args...)
-			return cmd.Run()
-		}
+	var typeCode typeFn
 
-		return nil
+	cmd := createGenerateCmd(cfg, "type", "Simulate typing of the TOTP code", func(code vault.Code, newLine bool) error {
+		return typeCode(code.Value, newLine)
+	})
+	selectBackendOnPreRun(cmd, func() error {
+		var err error
+		typeCode, err = typeBackend(cfg.TypeBackend)
+		return err
 	})
-}
 
-func CreateClipboardCmd(cfg *config.Config) *cobra.Command {
-	var xselTargets string
+	cmd.Flags().StringVar(&cfg.TypeBackend, backendFlag, backendAuto, "tool used to type the code ("+strings.Join(typeBackendNames(), ", ")+" or auto to detect it from the session)")
 
-	cmd := createGenerateCmd(cfg, "clipboard", "Copy the TOTP code to the clipboard", func(code string, newLine bool) error {
-		for _, target := range strings.Split(xselTargets, ",") {
-			var cmd *exec.Cmd
-			if runtime.GOOS == config.DarwinGOOS {
-				cmd = exec.Command(pbcopyCmd, "<<<", target)
-			} else {
-				cmd = exec.Command(xselCmd, "--input", "--"+target)
-			}
-			pipe, err := cmd.StdinPipe()
-			if err != nil {
-				return err
-			}
-			if err := cmd.Start(); err != nil {
-				return err
-			}
+	return cmd
+}
 
-			if newLine {
-				code += "\n"
-			}
-			if _, err := pipe.Write([]byte(code)); err != nil {
-				return err
+func CreateClipboardCmd(cfg *config.Config) *cobra.Command {
+	var (
+		targets    string
+		cb         clipboard
+		clearAfter time.Duration
+		noClear    bool
+	)
+
+	cmd := createGenerateCmd(cfg, "clipboard", "Copy the TOTP code to the clipboard", func(code vault.Code, newLine bool) error {
+		text := code.Value
+		if newLine {
+			text += "\n"
+		}
+		targetList := strings.Split(targets, ",")
+
+		var startClear func() error
+		if !noClear {
+			at := code.ExpiresAt
+			if clearAfter > 0 {
+				at = time.Now().Add(clearAfter)
+			} else if at.IsZero() {
+				// HOTP codes don't expire, they are cleared after the default TOTP period
+				at = time.Now().Add(secret.DefaultPeriod * time.Second)
 			}
 
-			if err := pipe.Close(); err != nil {
-				return err
-			}
-			if err := cmd.Wait(); err != nil {
+			var err error
+			if startClear, err = scheduleClear(cb, targetList, text, at); err != nil {
 				return err
 			}
 		}
 
+		if err := copyToClipboard(cb, targetList, text); err != nil {
+			return err
+		}
+		if startClear != nil {
+			return startClear()
+		}
 		return nil
 	})
+	selectBackendOnPreRun(cmd, func() error {
+		var err error
+		cb, err = selectClipboard(cfg.ClipboardBackend)
+		return err
+	})
 
-	cmd.Flags().StringVar(&xselTargets, xselTargetsFlag, defaultXselTargets, "comma-separated xsel targets (clipboard, primary or secondary)")
+	cmd.Flags().StringVar(&targets, xselTargetsFlag, defaultXselTargets, "comma-separated selections to copy the code to (clipboard, primary or secondary), the ones not supported by the backend are skipped")
+	cmd.Flags().DurationVar(&clearAfter, clearAfterFlag, 0, "restore the previous clipboard content after this time (0 for when the code expires)")
+	cmd.Flags().BoolVar(&noClear, noClearFlag, false, "leave the code in the clipboard")
+	cmd.Flags().StringVar(&cfg.ClipboardBackend, backendFlag, backendAuto, "tool used to copy the code ("+strings.Join(clipboardNames(), ", ")+" or auto to detect it from the session)")
 
 	return cmd
 }
 
 func CreatePrintCmd(cfg *config.Config) *cobra.Command {
-	return createGenerateCmd(cfg, "print", "Print the TOTP code to the stdout", func(code string, newLine bool) error {
+	return createGenerateCmd(cfg, "print", "Print the TOTP code to the stdout", func(code vault.Code, newLine bool) error {
 		if newLine {
-			fmt.Println(code)
+			fmt.Println(code.Value)
 		} else {
-			fmt.Print(code)
+			fmt.Print(code.Value)
 		}
 		return nil
 	})
 }
 
-func createGenerateCmd(cfg *config.Config, name, description string, handlerFn func(code string, newLine bool) error) *cobra.Command {
-	var newLine bool
+func createGenerateCmd(cfg *config.Config, name, description string, handlerFn func(code vault.Code, newLine bool) error) *cobra.Command {
+	var (
+		newLine     bool
+		minValidity time.Duration
+		pick        bool
+		launcher    string
+	)
 
 	cmd := &cobra.Command{
-		Use:   name + " CLIENT_ID",
+		Use:   name + " CLIENT_ID | --pick",
 		Short: description,
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.RangeArgs(0, 1),
 		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
-			secrets, err := vlt.GetSecrets()
-			if err != nil {
-				return err
+			if pick == (len(args) == 1) {
+				return fmt.Errorf("Either CLIENT_ID or the --%s option must be passed", pickFlag)
 			}
 
-			if secret := secrets[args[0]]; secret != "" {
-				code, err := totp.GenerateCode(secret, time.Now())
+			var clientID string
+			if pick {
+				clientIDs, err := vlt.ListClients()
 				if err != nil {
 					return err
 				}
+				if clientID, err = pickClient(clientIDs, launcher); err != nil {
+					return err
+				}
+			} else {
+				clientID = args[0]
+			}
 
-				return handlerFn(code, newLine)
+			code, err := vlt.GenerateCode(clientID, false)
+			if err != nil {
+				return err
 			}
 
-			return vault.ErrClientNotFound
+			if minValidity > 0 && !code.ExpiresAt.IsZero() {
+				period := time.Duration(code.Period) * time.Second
+				if minValidity >= period {
+					return fmt.Errorf("The --%s must be shorter than the %s period of the %s client", minValidityFlag, period, clientID)
+				}
+				if time.Until(code.ExpiresAt) < minValidity {
+					// the next code is valid for the whole period once it starts, so a single wait is enough
+					if code, err = vlt.GenerateCode(clientID, true); err != nil {
+						return err
+					}
+					validFrom := code.ExpiresAt.Add(-period)
+					notify("Waiting %s for the next code\n", time.Until(validFrom).Round(time.Second))
+					time.Sleep(time.Until(validFrom))
+				}
+				notify("The code is valid for %s\n", time.Until(code.ExpiresAt).Round(time.Second))
+			}
+
+			return handlerFn(code, newLine)
 		}),
 	}
 
 	cmd.Flags().BoolVarP(&newLine, newLineFlag, "n", false, "Append a newline character to the generated TOTP code")
+	cmd.Flags().DurationVar(&minValidity, minValidityFlag, 0, "wait for the next TOTP code if the current one expires sooner than this (e.g. 5s), must be shorter than the TOTP period")
+	cmd.Flags().BoolVar(&pick, pickFlag, false, "pick the client interactively instead of passing CLIENT_ID")
+	cmd.Flags().StringVar(&launcher, launcherFlag, launcherAuto, "launcher used to pick the client ("+strings.Join(picker.Launchers(), ", ")+", any command reading the client IDs from the standard input, or auto to use the built-in selector in a terminal)")
 
 	return cmd
 }
+
+// pickClient uses the built-in selector when run in a terminal, unless a launcher has been chosen explicitly
+func pickClient(clientIDs []string, launcher string) (string, error) {
+	if len(clientIDs) == 0 {
+		return "", fmt.Errorf("The vault is empty")
+	}
+
+	if launcher == launcherAuto {
+		if terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stderr.Fd())) {
+			return picker.Fuzzy(os.Stdin, os.Stderr, clientIDs)
+		}
+		launcher = detectLauncher()
+	}
+
+	return picker.Launch(picker.LauncherCommand(launcher), clientIDs)
+}
+
+// detectLauncher prefers the launchers native to the session, falling back to rofi which works in both
+func detectLauncher() string {
+	preferred := []string{picker.LauncherRofi, picker.LauncherDmenu}
+	if isWayland() {
+		preferred = []string{picker.LauncherWofi, picker.LauncherRofi}
+	}
+
+	for _, launcher := range preferred {
+		if _, err := exec.LookPath(launcher); err == nil {
+			return launcher
+		}
+	}
+	return preferred[0]
+}
+
+// notify prints the message for the user running the command in a terminal
+func notify(format string, args ...interface{}) {
+	if terminal.IsTerminal(int(os.Stderr.Fd())) {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
@@ -0,0 +1,166 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/importer"
+	"github.com/nordcloud/mfacli/pkg/secret"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+const (
+	formatFlag         = "format"
+	onConflictFlag     = "on-conflict"
+	backupPasswordFlag = "backup-password"
+
+	conflictFail      = "fail"
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+func Create(cfg *config.Config) *cobra.Command {
+	var (
+		format         string
+		onConflict     string
+		backupPassword secret.SecretValue
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import SOURCE...",
+		Short: "Import clients exported from another authenticator",
+		Long: "Import clients exported from another authenticator. For the Google Authenticator \"Transfer accounts\" " +
+			"export a SOURCE is an image file with the QR code, qr-scan to scan the QR code from the screen, or the " +
+			"otpauth-migration:// URI; all QR codes of a multi-page export must be passed at once. For the other formats " +
+			"a SOURCE is a plain or encrypted backup file.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
+			switch onConflict {
+			case conflictFail, conflictSkip, conflictOverwrite, conflictRename:
+			default:
+				return fmt.Errorf("Invalid --%s value: %s", onConflictFlag, onConflict)
+			}
+
+			entries, err := importer.Parse(format, args, importer.Options{
+				Password: func() (string, error) {
+					return backupPassword.ReadSecret("Backup password: ", "")
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			imported := assignClientIDs(entries)
+			clientIDs := sortedClientIDs(imported)
+
+			vaultClientIDs, err := vlt.ListClients()
+			if err != nil {
+				return err
+			}
+			existing := make(map[string]secret.Entry, len(vaultClientIDs))
+			for _, clientID := range vaultClientIDs {
+				existing[clientID] = secret.Entry{}
+			}
+
+			var conflicts []string
+			for _, clientID := range clientIDs {
+				if _, ok := existing[clientID]; ok {
+					conflicts = append(conflicts, clientID)
+				}
+			}
+			if len(conflicts) > 0 && onConflict == conflictFail {
+				return fmt.Errorf("The client IDs %s already exist in the vault. Pass --%s option to skip, overwrite or rename them.", strings.Join(conflicts, ", "), onConflictFlag)
+			}
+
+			// the changes are refused as a whole if any of the new client IDs is added to the vault meanwhile
+			var changes []vault.Change
+			var messages []string
+			for _, clientID := range clientIDs {
+				entry := imported[clientID]
+				if _, ok := existing[clientID]; !ok {
+					changes = append(changes, vault.PutEntry(clientID, entry, false))
+					messages = append(messages, fmt.Sprintf("Imported %s", clientID))
+					continue
+				}
+
+				switch onConflict {
+				case conflictSkip:
+					messages = append(messages, fmt.Sprintf("Skipped %s, the client ID already exists", clientID))
+				case conflictOverwrite:
+					changes = append(changes, vault.PutEntry(clientID, entry, true))
+					messages = append(messages, fmt.Sprintf("Overwritten %s", clientID))
+				case conflictRename:
+					newClientID := uniqueClientID(clientID, existing, imported)
+					existing[newClientID] = entry
+					changes = append(changes, vault.PutEntry(newClientID, entry, false))
+					messages = append(messages, fmt.Sprintf("Imported %s as %s", clientID, newClientID))
+				}
+			}
+
+			if len(changes) > 0 {
+				if err := vlt.ApplyChanges(changes...); err != nil {
+					return err
+				}
+			}
+			for _, message := range messages {
+				fmt.Fprintln(os.Stderr, message)
+			}
+			return nil
+		}),
+	}
+
+	cmd.Flags().StringVar(&format, formatFlag, importer.FormatGoogleAuthenticator, "format of the sources ("+strings.Join(importer.Formats(), ", ")+")")
+	cmd.Flags().StringVar(&onConflict, onConflictFlag, conflictFail, "what to do with the clients whose IDs already exist in the vault (fail, skip, overwrite or rename)")
+	cmd.Flags().Var(&backupPassword, backupPasswordFlag, "password of an encrypted backup in a format accepted by openssl (env:*, file:* or pass:*)")
+
+	return cmd
+}
+
+// assignClientIDs names the imported entries after their issuer and account, adding a suffix to duplicate names
+func assignClientIDs(entries []secret.Entry) map[string]secret.Entry {
+	result := make(map[string]secret.Entry, len(entries))
+
+	for i, entry := range entries {
+		base := entry.SuggestedClientID()
+		if base == "" {
+			base = fmt.Sprintf("imported-%d", i+1)
+		}
+
+		result[uniqueClientID(base, result)] = entry
+	}
+
+	return result
+}
+
+// uniqueClientID returns the client ID, suffixed with a number if needed, which is not used in any of the maps
+func uniqueClientID(base string, used ...map[string]secret.Entry) string {
+	clientID := base
+	for n := 2; ; n++ {
+		taken := false
+		for _, m := range used {
+			if _, ok := m[clientID]; ok {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return clientID
+		}
+		clientID = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+func sortedClientIDs(entries map[string]secret.Entry) []string {
+	clientIDs := make([]string, 0, len(entries))
+	for clientID := range entries {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Strings(clientIDs)
+	return clientIDs
+}
@@ -2,7 +2,6 @@ package list
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -16,17 +15,11 @@ func Create(cfg *config.Config) *cobra.Command {
 		Short: "List all registered client IDs",
 		Args:  cobra.ExactArgs(0),
 		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
-			secrets, err := vlt.GetSecrets()
+			names, err := vlt.ListClients()
 			if err != nil {
 				return err
 			}
 
-			names := make([]string, 0, len(secrets))
-			for name := range secrets {
-				names = append(names, name)
-			}
-			sort.Strings(names)
-
 			for _, name := range names {
 				fmt.Println(name)
 			}
@@ -13,10 +13,7 @@ func Create(cfg *config.Config) *cobra.Command {
 		Short: "Remove client ID from the vault",
 		Args:  cobra.ExactArgs(1),
 		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
-			return vlt.ModifySecrets(func(secrets map[string]string) error {
-				delete(secrets, args[0])
-				return nil
-			})
+			return vlt.ApplyChanges(vault.RemoveEntry(args[0]))
 		}),
 	}
 }
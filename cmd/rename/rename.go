@@ -13,18 +13,7 @@ func Create(cfg *config.Config) *cobra.Command {
 		Short: "Rename the client",
 		Args:  cobra.ExactArgs(2),
 		RunE: vault.RunOnVault(cfg, func(vlt vault.Vault, args ...string) error {
-			return vlt.ModifySecrets(func(secrets map[string]string) error {
-				old, new := args[0], args[1]
-
-				secret := secrets[old]
-				if secret == "" {
-					return vault.ErrClientNotFound
-				}
-
-				secrets[new] = secret
-				delete(secrets, old)
-				return nil
-			})
+			return vlt.ApplyChanges(vault.RenameEntry(args[0], args[1], true))
 		}),
 	}
 }
@@ -9,9 +9,12 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/nordcloud/mfacli/cmd/add"
+	"github.com/nordcloud/mfacli/cmd/audit"
+	"github.com/nordcloud/mfacli/cmd/changepassword"
 	"github.com/nordcloud/mfacli/cmd/doc"
-	"github.com/nordcloud/mfacli/cmd/dump"
+	"github.com/nordcloud/mfacli/cmd/export"
 	"github.com/nordcloud/mfacli/cmd/generate"
+	"github.com/nordcloud/mfacli/cmd/importer"
 	"github.com/nordcloud/mfacli/cmd/list"
 	"github.com/nordcloud/mfacli/cmd/remove"
 	"github.com/nordcloud/mfacli/cmd/rename"
@@ -33,7 +36,12 @@ func initDataDir() (string, error) {
 	}
 
 	dir = filepath.Join(dir, config.DataDirName)
-	err = os.MkdirAll(dir, os.FileMode(0755))
+	err = os.MkdirAll(dir, os.FileMode(0700))
+	if err != nil {
+		return "", err
+	}
+	// the directory might have been created with wider permissions by the older versions
+	err = os.Chmod(dir, os.FileMode(0700))
 	if err != nil {
 		return "", err
 	}
@@ -62,29 +70,45 @@ func createRootCmd() *cobra.Command {
 	}
 }
 
-func addFlags(rootCmd *cobra.Command, defaultSocket, defaultVault string) {
+func addFlags(rootCmd *cobra.Command, defaultSocket, defaultVault, defaultAuditLog string) {
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Show version")
 
 	rootCmd.PersistentFlags().StringVarP(&globalCfg.SocketPath, "socket", "S", defaultSocket, "custom Unix socket path to bind (if server) or to connect (if client)")
 	rootCmd.PersistentFlags().StringVarP(&globalCfg.VaultPath, "vault", "V", defaultVault, "custom encrypted vault file")
 	rootCmd.PersistentFlags().StringVar(&globalCfg.ServerLogFile, config.FlagServerLogFile, "", "Server log file")
+	rootCmd.PersistentFlags().DurationVar(&globalCfg.IdleTimeout, config.FlagIdleTimeout, config.DefaultIdleTimeout, "stop the cache server after it hasn't been used for this long (0 to never)")
+	rootCmd.PersistentFlags().DurationVar(&globalCfg.MaxLifetime, config.FlagMaxLifetime, config.DefaultMaxLifetime, "stop the cache server this long after it has been started (0 to never)")
+	rootCmd.PersistentFlags().BoolVar(&globalCfg.LockOnScreensaver, config.FlagLockOnScreensaver, false, "lock the cache server when the screensaver of the desktop session activates")
+	rootCmd.PersistentFlags().StringArrayVar(&globalCfg.AllowedExecutables, config.FlagAllowedExecutable, nil, "path of an executable (besides mfacli itself) allowed to request codes from the cache server, can be repeated (all are allowed if not set)")
+	rootCmd.PersistentFlags().StringVar(&globalCfg.AuditLog, config.FlagAuditLog, defaultAuditLog, "file the cache server logs the served requests to (empty to disable)")
+	rootCmd.PersistentFlags().UintVar(&globalCfg.AuditLogMaxSize, config.FlagAuditLogMaxSize, config.DefaultAuditLogMaxSize, "size in MiB after which the audit log is rotated (0 to never)")
 	rootCmd.PersistentFlags().BoolVar(&globalCfg.NoCache, "no-cache", false, "don't use vault cache server")
 	rootCmd.PersistentFlags().Var(&globalCfg.Password, "password", "vault password in a format accepted by openssl (env:*, file:* or pass:*)")
 	rootCmd.PersistentFlags().StringVar(&globalCfg.PasswordCommand, "password-command", "", "an optional command for reading the password")
+	rootCmd.PersistentFlags().Uint32Var(&globalCfg.KDFTime, "kdf-time", 0, "number of Argon2id iterations used when a new vault key is derived (0 for the default, at most 16)")
+	rootCmd.PersistentFlags().Uint32Var(&globalCfg.KDFMemory, "kdf-memory", 0, "Argon2id memory cost in KiB used when a new vault key is derived (0 for the default, at most 1 GiB)")
 }
 
 func addSubcommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(generate.CreatePrintCmd(&globalCfg))
 	rootCmd.AddCommand(generate.CreateClipboardCmd(&globalCfg))
 	rootCmd.AddCommand(generate.CreateTypeCmd(&globalCfg))
+	rootCmd.AddCommand(generate.CreateClearClipboardCmd(&globalCfg))
 	rootCmd.AddCommand(add.Create(&globalCfg))
+	rootCmd.AddCommand(importer.Create(&globalCfg))
 	rootCmd.AddCommand(list.Create(&globalCfg))
-	rootCmd.AddCommand(dump.Create(&globalCfg))
+	rootCmd.AddCommand(export.Create(&globalCfg))
 	rootCmd.AddCommand(remove.Create(&globalCfg))
 	rootCmd.AddCommand(rename.Create(&globalCfg))
+	rootCmd.AddCommand(changepassword.Create(&globalCfg))
 	rootCmd.AddCommand(server.CreateRunCmd(&globalCfg))
 	rootCmd.AddCommand(server.CreateStartCmd(&globalCfg))
 	rootCmd.AddCommand(server.CreateStopCmd(&globalCfg))
+	rootCmd.AddCommand(server.CreateStatusCmd(&globalCfg))
+	rootCmd.AddCommand(server.CreateLockCmd(&globalCfg))
+	rootCmd.AddCommand(server.CreateUnlockCmd(&globalCfg))
+	rootCmd.AddCommand(server.CreateServerCmd(&globalCfg))
+	rootCmd.AddCommand(audit.Create(&globalCfg))
 	rootCmd.AddCommand(createBachCompletionCmd())
 	doc.Bind(rootCmd)
 }
@@ -101,7 +125,8 @@ func Execute() error {
 
 	socketPath := filepath.Join(dataDir, config.DefaultSocketName)
 	vaultPath := filepath.Join(dataDir, config.DefaultVaultName)
-	addFlags(rootCmd, socketPath, vaultPath)
+	auditLogPath := filepath.Join(dataDir, config.DefaultAuditLogName)
+	addFlags(rootCmd, socketPath, vaultPath, auditLogPath)
 
 	addSubcommands(rootCmd)
 
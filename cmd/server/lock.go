@@ -0,0 +1,30 @@
+package server
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+func CreateLockCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lock",
+		Short: "Wipe the secrets from the memory of the cache server without stopping it",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return vault.LockServer(cfg)
+		},
+	}
+}
+
+func CreateUnlockCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the locked cache server with the vault password",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return vault.UnlockServer(cfg)
+		},
+	}
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+)
+
+// CreateServerCmd groups the commands setting up the cache server. The commands controlling the running server
+// (start-server, stop-server, lock etc.) stay at the top level, where they have always been.
+func CreateServerCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "server",
+		Short: "Set up the credentials cache server",
+		Args:  cobra.ExactArgs(0),
+	}
+
+	cmd.AddCommand(CreateInstallSystemdCmd(cfg))
+	return cmd
+}
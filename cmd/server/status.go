@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+func CreateStatusCmd(cfg *config.Config) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "server-status",
+		Short: "Show the status of the credentials cache server, exits with non-zero code if it's not running",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := vault.GetServerStatus(cfg)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(status)
+			}
+
+			expires := "never"
+			if !status.ExpiresAt.IsZero() {
+				remaining := time.Until(status.ExpiresAt).Round(time.Second)
+				expires = fmt.Sprintf("%s (in %s)", status.ExpiresAt.Format(time.RFC3339), remaining)
+			}
+
+			fmt.Printf("PID:        %d\n", status.PID)
+			fmt.Printf("Socket:     %s\n", status.SocketPath)
+			fmt.Printf("Vault:      %s\n", status.VaultPath)
+			fmt.Printf("Started:    %s (up %s)\n", status.StartTime.Format(time.RFC3339), time.Since(status.StartTime).Round(time.Second))
+			fmt.Printf("Expires:    %s\n", expires)
+			if status.Locked {
+				fmt.Printf("Entries:    locked\n")
+			} else {
+				fmt.Printf("Entries:    %d\n", status.Entries)
+			}
+			fmt.Printf("Requests:   %d\n", status.Requests)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the status in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/vault"
+)
+
+const (
+	systemdUnitName = config.CommandName
+
+	socketUnitTemplate = `[Unit]
+Description=%[1]s credentials cache server socket
+
+[Socket]
+ListenStream=%[2]s
+SocketMode=0600
+DirectoryMode=0700
+
+[Install]
+WantedBy=sockets.target
+`
+
+	serviceUnitTemplate = `[Unit]
+Description=%[1]s credentials cache server
+Requires=%[1]s.socket
+
+[Service]
+ExecStart=%[2]s
+`
+)
+
+func CreateInstallSystemdCmd(cfg *config.Config) *cobra.Command {
+	var (
+		outputDir string
+		force     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install-systemd",
+		Short: "Install systemd user units starting the credentials cache server on demand",
+		Long: "Install systemd user units starting the credentials cache server on demand. The server is started " +
+			"locked by systemd when a client connects to the socket, and asks for the password on the first use. " +
+			"The server options (--socket, --vault, --idle-timeout etc.) are written to the units.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := vault.ValidateServerConfig(cfg); err != nil {
+				return err
+			}
+			if outputDir == "" {
+				dir, err := systemdUserDir()
+				if err != nil {
+					return err
+				}
+				outputDir = dir
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			// the units don't depend on the working directory
+			serverCfg := *cfg
+			for _, path := range []*string{&serverCfg.SocketPath, &serverCfg.VaultPath, &serverCfg.ServerLogFile, &serverCfg.AuditLog} {
+				if *path == "" {
+					continue
+				}
+				if *path, err = filepath.Abs(*path); err != nil {
+					return err
+				}
+			}
+			execArgs := append([]string{executable, config.InternalRunServerCmd}, vault.ServerArgs(&serverCfg)...)
+
+			units := map[string]string{
+				systemdUnitName + ".socket":  fmt.Sprintf(socketUnitTemplate, systemdUnitName, escapeSystemdPath(serverCfg.SocketPath)),
+				systemdUnitName + ".service": fmt.Sprintf(serviceUnitTemplate, systemdUnitName, systemdCommandLine(execArgs)),
+			}
+
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return err
+			}
+			for _, name := range []string{systemdUnitName + ".socket", systemdUnitName + ".service"} {
+				if err := writeUnit(filepath.Join(outputDir, name), units[name], force); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(os.Stderr, "Enable the socket with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s.socket\n", systemdUnitName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outputDir, "output-dir", "", "directory to write the units to (defaults to the systemd user unit directory)")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite the existing units")
+
+	return cmd
+}
+
+func systemdUserDir() (string, error) {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "systemd", "user"), nil
+}
+
+func writeUnit(path, content string, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists, pass --force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Written %s\n", path)
+	return file.Close()
+}
+
+// systemdCommandLine quotes the arguments as expected by ExecStart, see systemd.service(5)
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(arg)
+		if arg == "" || strings.ContainsAny(arg, " \t'\"") {
+			arg = `"` + arg + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// escapeSystemdPath escapes the specifiers in a path used in a unit file
+func escapeSystemdPath(path string) string {
+	return strings.ReplaceAll(path, "%", "%%")
+}
@@ -1,17 +1,32 @@
 package config
 
 import (
+	"time"
+
 	"github.com/nordcloud/mfacli/pkg/secret"
 )
 
 const (
-	CommandName          = "mfacli"
-	DataDirName          = "." + CommandName
-	DefaultSocketName    = CommandName + ".sock"
-	DefaultVaultName     = CommandName + ".vault"
-	InternalRunServerCmd = "_run_server"
+	CommandName               = "mfacli"
+	DataDirName               = "." + CommandName
+	DefaultSocketName         = CommandName + ".sock"
+	DefaultVaultName          = CommandName + ".vault"
+	DefaultAuditLogName       = "audit.log"
+	InternalRunServerCmd      = "_run_server"
+	InternalClearClipboardCmd = "_clear_clipboard"
+
+	FlagServerLogFile     = "server-log-file"
+	FlagIdleTimeout       = "idle-timeout"
+	FlagMaxLifetime       = "max-lifetime"
+	FlagLockOnScreensaver = "lock-on-screensaver"
+	FlagAllowedExecutable = "allowed-executable"
+	FlagAuditLog          = "audit-log"
+	FlagAuditLogMaxSize   = "audit-log-max-size"
 
-	FlagServerLogFile = "server-log-file"
+	DefaultIdleTimeout = 0
+	DefaultMaxLifetime = 8 * time.Hour
+	// DefaultAuditLogMaxSize is in MiB
+	DefaultAuditLogMaxSize = 10
 
 	DarwinGOOS = "darwin"
 )
@@ -27,4 +42,15 @@ type Config struct {
 	Password        secret.SecretValue
 	ServerLogFile   string
 	PasswordCommand string
+	KDFTime         uint32
+	KDFMemory       uint32
+	IdleTimeout     time.Duration
+	MaxLifetime     time.Duration
+
+	LockOnScreensaver  bool
+	AllowedExecutables []string
+	AuditLog           string
+	AuditLogMaxSize    uint // in MiB
+	TypeBackend        string
+	ClipboardBackend   string
 }
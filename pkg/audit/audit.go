@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ActionGetSecrets   = "get_secrets"
+	ActionStoreSecrets = "store_secrets"
+	ActionGenerateCode = "generate_code"
+	ActionChangeKey    = "change_key"
+
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+
+	// rotatedSuffix is appended to the name of the log file when it's rotated, only one old file is kept
+	rotatedSuffix = ".1"
+)
+
+// Event is a single line of the audit log
+type Event struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	ClientID string    `json:"client_id,omitempty"`
+	// NewClientID is the target of a rename
+	NewClientID string `json:"new_client_id,omitempty"`
+	PID         int    `json:"pid,omitempty"`
+	Executable  string `json:"executable,omitempty"`
+	Outcome     string `json:"outcome"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Logger appends the events to a file as JSON lines. The file is rotated once it exceeds maxSize,
+// unless maxSize is 0.
+type Logger struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+func NewLogger(path string, maxSize int64) (*Logger, error) {
+	logger := &Logger{path: path, maxSize: maxSize}
+	if err := logger.open(); err != nil {
+		return nil, err
+	}
+	return logger, nil
+}
+
+func (l *Logger) Log(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+func (l *Logger) open() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.path+rotatedSuffix); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Read returns the events logged since the given time (all of them if it's zero), oldest first.
+// The rotated file is read as well.
+func Read(path string, since time.Time) ([]Event, error) {
+	var events []Event
+	for _, name := range []string{path + rotatedSuffix, path} {
+		fileEvents, err := readFile(name, since)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, fileEvents...)
+	}
+	return events, nil
+}
+
+func readFile(path string, since time.Time) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// a line might have been cut off when the disk got full, it's skipped rather than failing the whole query
+			continue
+		}
+		if event.Time.Before(since) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
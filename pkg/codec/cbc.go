@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// aesCBC encrypts the payload with AES-256 in CBC mode. The key is prepended to the plaintext
+// so that an invalid password can be told apart when decrypting. The payload is not authenticated
+// so this cipher is only kept for reading vaults written by older versions.
+type aesCBC struct{}
+
+func (aesCBC) seal(key *Key, header, plaintext []byte) ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	src := append(append([]byte{}, key.Value...), pad(plaintext)...)
+
+	c, err := aes.NewCipher(key.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, len(src))
+	encrypter := cipher.NewCBCEncrypter(c, iv)
+	encrypter.CryptBlocks(ciphertext, src)
+
+	return append(iv, ciphertext...), nil
+}
+
+func (aesCBC) open(key *Key, header, encrypted []byte) ([]byte, error) {
+	if len(encrypted) < aes.BlockSize+keySize+aes.BlockSize || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid ciphertext length", ErrIntegrity)
+	}
+
+	c, err := aes.NewCipher(key.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, iv := encrypted[aes.BlockSize:], encrypted[:aes.BlockSize]
+	decrypted := make([]byte, len(ciphertext))
+	decrypter := cipher.NewCBCDecrypter(c, iv)
+	decrypter.CryptBlocks(decrypted, ciphertext)
+
+	decryptedKey, decrypted := decrypted[:keySize], decrypted[keySize:]
+	if bytes.Compare(decryptedKey, key.Value) != 0 {
+		return nil, ErrInvalidPassword
+	}
+
+	return unpad(decrypted)
+}
+
+func pad(src []byte) []byte {
+	length := len(src)
+	padValue := aes.BlockSize - length%aes.BlockSize
+
+	dst := make([]byte, length+padValue)
+	for i := 0; i < length; i++ {
+		dst[i] = src[i]
+	}
+
+	for i := 0; i < padValue; i++ {
+		dst[length+i] = byte(padValue)
+	}
+
+	return dst
+}
+
+func unpad(src []byte) ([]byte, error) {
+	length := len(src)
+	if length == 0 {
+		return nil, fmt.Errorf("%w: invalid padding", ErrIntegrity)
+	}
+
+	padValue := int(src[length-1])
+	if padValue == 0 || padValue > aes.BlockSize || padValue > length {
+		return nil, fmt.Errorf("%w: invalid padding", ErrIntegrity)
+	}
+	for _, b := range src[length-padValue:] {
+		if int(b) != padValue {
+			return nil, fmt.Errorf("%w: invalid padding", ErrIntegrity)
+		}
+	}
+
+	return src[:length-padValue], nil
+}
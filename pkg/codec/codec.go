@@ -2,60 +2,88 @@ package codec
 
 import (
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	CipherAES256CBC = "aes-256-cbc"
+	CipherAES256GCM = "aes-256-gcm"
+
+	DefaultCipher = CipherAES256GCM
+
+	keyCheckLabel = "mfacli key check"
+	keyCheckSize  = 16
 )
 
 var (
 	ErrInvalidPassword = fmt.Errorf("Invalid password")
-)
+	ErrIntegrity       = fmt.Errorf("Vault integrity check failed, the file is corrupted or has been tampered with")
 
-func BuildEncKey(password string) []byte {
-	sum := sha256.Sum256([]byte(password))
-	result := make([]byte, sha256.Size)
-	for i := 0; i < sha256.Size; i++ {
-		result[i] = sum[i]
+	ciphers = map[string]payloadCipher{
+		CipherAES256CBC: aesCBC{},
+		CipherAES256GCM: aesGCM{},
 	}
-	return result
+)
+
+// payloadCipher encrypts the vault payload. The raw header is passed so that it can be authenticated.
+type payloadCipher interface {
+	seal(key *Key, header, plaintext []byte) ([]byte, error)
+	open(key *Key, header, ciphertext []byte) ([]byte, error)
 }
 
-func Decrypt(encrypted []byte, key []byte) (map[string]string, error) {
-	if len(encrypted) <= aes.BlockSize {
-		return nil, fmt.Errorf("Ciphertext is too short")
+// ReadKDFParams returns the KDF parameters the vault data was encrypted with
+func ReadKDFParams(encrypted []byte) (KDFParams, error) {
+	hdr, _, err := ReadHeader(encrypted)
+	if err != nil {
+		return KDFParams{}, err
 	}
+	return hdr.KDF, nil
+}
 
-	c, err := aes.NewCipher(key)
+func Decrypt(encrypted []byte, key *Key) (map[string]secret.Entry, error) {
+	hdr, payload, err := ReadHeader(encrypted)
 	if err != nil {
 		return nil, err
 	}
-
-	ciphertext, iv := encrypted[aes.BlockSize:], encrypted[:aes.BlockSize]
-	decrypted := make([]byte, len(ciphertext))
-	decrypter := cipher.NewCBCDecrypter(c, iv)
-	decrypter.CryptBlocks(decrypted, ciphertext)
-
-	decryptedKey, decrypted := decrypted[:sha256.Size], decrypted[sha256.Size:]
-	if bytes.Compare(decryptedKey, key) != 0 {
+	if hdr.KDF.Algorithm != key.KDF.Algorithm || !bytes.Equal(hdr.KDF.Salt, key.KDF.Salt) {
+		return nil, ErrInvalidPassword
+	}
+	if hdr.KeyCheck != nil && !hmac.Equal(hdr.KeyCheck, buildKeyCheck(key)) {
 		return nil, ErrInvalidPassword
 	}
 
-	decrypted = unpad(decrypted)
+	c, ok := ciphers[hdr.Cipher]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported vault cipher: %s", hdr.Cipher)
+	}
 
-	var secrets map[string]string
-	err = json.Unmarshal(decrypted, &secrets)
+	rawHeader := encrypted[:len(encrypted)-len(payload)]
+	decrypted, err := c.open(key, rawHeader, payload)
 	if err != nil {
 		return nil, err
 	}
+
+	var secrets map[string]secret.Entry
+	err = json.Unmarshal(decrypted, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrIntegrity, err.Error())
+	}
 	return secrets, nil
 }
 
-func Encrypt(secrets map[string]string, key []byte) ([]byte, error) {
-	iv := make([]byte, aes.BlockSize)
-	_, err := rand.Read(iv)
+func Encrypt(secrets map[string]secret.Entry, key *Key) ([]byte, error) {
+	hdr := &Header{
+		Version:  CurrentFormatVersion,
+		KDF:      key.KDF,
+		Cipher:   DefaultCipher,
+		KeyCheck: buildKeyCheck(key),
+	}
+	hdrData, err := hdr.Write()
 	if err != nil {
 		return nil, err
 	}
@@ -64,38 +92,18 @@ func Encrypt(secrets map[string]string, key []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	src = pad(src)
-	src = append(key, src...)
 
-	c, err := aes.NewCipher(key)
+	payload, err := ciphers[hdr.Cipher].seal(key, hdrData, src)
 	if err != nil {
 		return nil, err
 	}
 
-	ciphertext := make([]byte, len(src))
-	encrypter := cipher.NewCBCEncrypter(c, iv)
-	encrypter.CryptBlocks(ciphertext, src)
-
-	return append(iv, ciphertext...), nil
-}
-
-func pad(src []byte) []byte {
-	length := len(src)
-	padValue := aes.BlockSize - length%aes.BlockSize
-
-	dst := make([]byte, length+padValue)
-	for i := 0; i < length; i++ {
-		dst[i] = src[i]
-	}
-
-	for i := 0; i < padValue; i++ {
-		dst[length+i] = byte(padValue)
-	}
-
-	return dst
+	return append(hdrData, payload...), nil
 }
 
-func unpad(src []byte) []byte {
-	length := len(src)
-	return src[:length-int(src[length-1])]
+// buildKeyCheck returns a value stored in the header which allows telling an invalid password apart from a corrupted payload
+func buildKeyCheck(key *Key) []byte {
+	mac := hmac.New(sha256.New, key.Value)
+	mac.Write([]byte(keyCheckLabel))
+	return mac.Sum(nil)[:keyCheckSize]
 }
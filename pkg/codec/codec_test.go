@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+var (
+	testSecrets = map[string]secret.Entry{
+		"github": {Secret: "JBSWY3DPEHPK3PXP"},
+		"hot":    {Type: secret.TypeHOTP, Secret: "GEZDGNBVGY3TQOJQ", Counter: 7},
+	}
+)
+
+func newTestKey(t *testing.T, password string) *Key {
+	params, err := NewKDFParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep the tests fast, the limits are checked separately
+	params.Memory = 1024
+	params.Time = 1
+
+	key, err := BuildEncKey(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestDecryptV0(t *testing.T) {
+	key, err := BuildEncKey("password", KDFParams{Algorithm: KDFSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := json.Marshal(testSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the vaults written before the header was introduced contain just the CBC payload
+	data, err := aesCBC{}.seal(key, nil, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := ReadKDFParams(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Algorithm != KDFSHA256 {
+		t.Errorf("KDF algorithm = %q, want %q", params.Algorithm, KDFSHA256)
+	}
+
+	secrets, err := Decrypt(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secrets, testSecrets) {
+		t.Errorf("Decrypt() = %v, want %v", secrets, testSecrets)
+	}
+
+	wrongKey, err := BuildEncKey("wrong", KDFParams{Algorithm: KDFSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(data, wrongKey); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Decrypt() with a wrong password error = %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := newTestKey(t, "password")
+	data, err := Encrypt(testSecrets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hdr, _, err := ReadHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Version != CurrentFormatVersion || hdr.Cipher != CipherAES256GCM {
+		t.Errorf("header version %d cipher %q, want %d %q", hdr.Version, hdr.Cipher, CurrentFormatVersion, CipherAES256GCM)
+	}
+
+	secrets, err := Decrypt(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secrets, testSecrets) {
+		t.Errorf("Decrypt() = %v, want %v", secrets, testSecrets)
+	}
+
+	wrongKey, err := BuildEncKey("wrong", key.KDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(data, wrongKey); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Decrypt() with a wrong password error = %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestDecryptTamperedPayload(t *testing.T) {
+	key := newTestKey(t, "password")
+	data, err := Encrypt(testSecrets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 1
+	if _, err := Decrypt(data, key); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrIntegrity)
+	}
+
+	if _, err := Decrypt(data[:len(data)-20], key); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Decrypt() of a truncated payload error = %v, want %v", err, ErrIntegrity)
+	}
+}
+
+func TestDecryptTamperedHeader(t *testing.T) {
+	key := newTestKey(t, "password")
+	data, err := Encrypt(testSecrets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the header is authenticated as a whole, even the parameters not compared with the key
+	tampered := bytes.Replace(data, []byte(`"threads":4`), []byte(`"threads":5`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatal("threads not found in the header")
+	}
+	if _, err := Decrypt(tampered, key); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrIntegrity)
+	}
+}
+
+func TestKDFParamsLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*KDFParams)
+		valid  bool
+	}{
+		{"memory at the limit", func(p *KDFParams) { p.Memory = maxKDFMemory }, true},
+		{"time at the limit", func(p *KDFParams) { p.Time = maxKDFTime }, true},
+		{"salt at the limit", func(p *KDFParams) { p.Salt = make([]byte, maxSaltSize) }, true},
+		{"memory", func(p *KDFParams) { p.Memory = maxKDFMemory + 1 }, false},
+		{"huge memory", func(p *KDFParams) { p.Memory = 2 * 1024 * 1024 * 1024 }, false},
+		{"time", func(p *KDFParams) { p.Time = maxKDFTime + 1 }, false},
+		{"salt", func(p *KDFParams) { p.Salt = make([]byte, maxSaltSize+1) }, false},
+		{"zero threads", func(p *KDFParams) { p.Threads = 0 }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := NewKDFParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.modify(&params)
+
+			data, err := (&Header{Version: CurrentFormatVersion, KDF: params, Cipher: CipherAES256GCM}).Write()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ReadKDFParams(data)
+			if test.valid {
+				// the key isn't derived, as the parameters at the limits are expensive on purpose
+				if err != nil {
+					t.Errorf("ReadKDFParams() error = %v, want none", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("ReadKDFParams() error = %v, want %v", err, ErrInvalidHeader)
+			}
+			if _, err := BuildEncKey("password", params); !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("BuildEncKey() error = %v, want %v", err, ErrInvalidHeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// aesGCM seals the payload with AES-256-GCM, authenticating the vault header as additional data
+type aesGCM struct{}
+
+func (aesGCM) seal(key *Key, header, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, header), nil
+}
+
+func (aesGCM) open(key *Key, header, encrypted []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext is too short", ErrIntegrity)
+	}
+
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	decrypted, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrIntegrity
+	}
+
+	return decrypted, nil
+}
+
+func newGCM(key *Key) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key.Value)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
@@ -0,0 +1,110 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// The vault file starts with a header which describes how the payload following it is encrypted:
+//
+//	magic ("MFACLI") | version (1 byte) | header length (2 bytes, big endian) | JSON header | payload
+//
+// Files without the magic prefix were written before the header was introduced and are read as version 0.
+const (
+	FormatVersion0 = 0
+	FormatVersion1 = 1
+
+	CurrentFormatVersion = FormatVersion1
+)
+
+var (
+	ErrInvalidHeader = fmt.Errorf("Invalid vault header")
+
+	magic = []byte("MFACLI")
+
+	headerReaders = map[byte]func([]byte) (*Header, []byte, error){
+		FormatVersion1: readHeaderV1,
+	}
+)
+
+type Header struct {
+	Version  uint8     `json:"-"`
+	KDF      KDFParams `json:"kdf"`
+	Cipher   string    `json:"cipher"`
+	KeyCheck []byte    `json:"key_check,omitempty"`
+}
+
+// ReadHeader splits the vault data into the header and the encrypted payload
+func ReadHeader(data []byte) (*Header, []byte, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return readHeaderV0(data)
+	}
+	data = data[len(magic):]
+
+	if len(data) == 0 {
+		return nil, nil, ErrInvalidHeader
+	}
+	read, ok := headerReaders[data[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unsupported vault format version %d, upgrade mfacli", data[0])
+	}
+
+	return read(data[1:])
+}
+
+func (h *Header) Write() ([]byte, error) {
+	if h.Version != CurrentFormatVersion {
+		return nil, fmt.Errorf("Writing vault format version %d is not supported", h.Version)
+	}
+
+	body, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0xffff {
+		return nil, ErrInvalidHeader
+	}
+
+	result := make([]byte, 0, len(magic)+3+len(body))
+	result = append(result, magic...)
+	result = append(result, h.Version, 0, 0)
+	binary.BigEndian.PutUint16(result[len(result)-2:], uint16(len(body)))
+	return append(result, body...), nil
+}
+
+func readHeaderV0(data []byte) (*Header, []byte, error) {
+	return &Header{
+		Version: FormatVersion0,
+		KDF:     KDFParams{Algorithm: KDFSHA256},
+		Cipher:  CipherAES256CBC,
+	}, data, nil
+}
+
+func readHeaderV1(data []byte) (*Header, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+	length := int(binary.BigEndian.Uint16(data[:2]))
+	data = data[2:]
+	if len(data) < length {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	hdr := &Header{Version: FormatVersion1}
+	if err := json.Unmarshal(data[:length], hdr); err != nil {
+		return nil, nil, ErrInvalidHeader
+	}
+	if hdr.Cipher == "" {
+		// the cipher was not recorded by the early version 1 headers
+		hdr.Cipher = CipherAES256CBC
+	}
+	if hdr.KDF.Algorithm == KDFArgon2id {
+		if err := hdr.KDF.validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return hdr, data[length:], nil
+}
@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KDFArgon2id = "argon2id"
+	KDFSHA256   = "sha256" // legacy unsalted key derivation, only used for reading old vaults
+
+	keySize  = sha256.Size
+	saltSize = 16
+
+	// the limits of the parameters read from the unauthenticated header, a few times the defaults, so that
+	// a tampered vault can make the key derivation take at most a few seconds and 1 GiB of memory
+	maxKDFTime   = 16
+	maxKDFMemory = 1024 * 1024 // 1 GiB
+	maxSaltSize  = 1024
+)
+
+var (
+	DefaultKDFParams = KDFParams{
+		Algorithm: KDFArgon2id,
+		Time:      3,
+		Memory:    64 * 1024,
+		Threads:   4,
+	}
+)
+
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt,omitempty"`
+	Time      uint32 `json:"time,omitempty"`
+	Memory    uint32 `json:"memory,omitempty"` // in KiB
+	Threads   uint8  `json:"threads,omitempty"`
+}
+
+type Key struct {
+	KDF   KDFParams `json:"kdf"`
+	Value []byte    `json:"value"`
+}
+
+// NewKDFParams returns the default KDF parameters with a freshly generated salt
+func NewKDFParams() (KDFParams, error) {
+	params := DefaultKDFParams
+	params.Salt = make([]byte, saltSize)
+	if _, err := rand.Read(params.Salt); err != nil {
+		return KDFParams{}, err
+	}
+	return params, nil
+}
+
+func BuildEncKey(password string, params KDFParams) (*Key, error) {
+	var value []byte
+
+	switch params.Algorithm {
+	case KDFArgon2id:
+		if err := params.validate(); err != nil {
+			return nil, err
+		}
+		value = argon2.IDKey([]byte(password), params.Salt, params.Time, params.Memory, params.Threads, keySize)
+	case KDFSHA256:
+		sum := sha256.Sum256([]byte(password))
+		value = sum[:]
+	default:
+		return nil, fmt.Errorf("Unsupported key derivation function: %s", params.Algorithm)
+	}
+
+	return &Key{
+		KDF:   params,
+		Value: value,
+	}, nil
+}
+
+// validate checks the Argon2 parameters, which may come from a corrupted or tampered header
+func (p KDFParams) validate() error {
+	if len(p.Salt) == 0 || p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return fmt.Errorf("%w: invalid %s parameters", ErrInvalidHeader, KDFArgon2id)
+	}
+	if len(p.Salt) > maxSaltSize || p.Time > maxKDFTime || p.Memory > maxKDFMemory {
+		return fmt.Errorf("%w: %s parameters exceed the supported limits", ErrInvalidHeader, KDFArgon2id)
+	}
+	return nil
+}
+
+// IsLegacy reports whether the key has been derived with an outdated KDF and should be replaced
+func (k *Key) IsLegacy() bool {
+	return k.KDF.Algorithm != DefaultKDFParams.Algorithm
+}
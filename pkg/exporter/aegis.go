@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	aegisVersion   = 1
+	aegisDBVersion = 2
+)
+
+type aegisBackup struct {
+	Version int         `json:"version"`
+	Header  aegisHeader `json:"header"`
+	DB      aegisDB     `json:"db"`
+}
+
+type aegisHeader struct {
+	Slots  []struct{} `json:"slots"`
+	Params *struct{}  `json:"params"`
+}
+
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+}
+
+type aegisEntry struct {
+	Type   string    `json:"type"`
+	UUID   string    `json:"uuid"`
+	Name   string    `json:"name"`
+	Issuer string    `json:"issuer"`
+	Info   aegisInfo `json:"info"`
+}
+
+type aegisInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  uint   `json:"period,omitempty"`
+	Counter uint64 `json:"counter"`
+}
+
+// writeAegis writes the plain (unencrypted) Aegis Authenticator vault export
+func writeAegis(entries map[string]secret.Entry, opts Options) ([]byte, error) {
+	backup := aegisBackup{
+		Version: aegisVersion,
+		DB: aegisDB{
+			Version: aegisDBVersion,
+			Entries: make([]aegisEntry, 0, len(entries)),
+		},
+	}
+
+	for _, clientID := range sortedClientIDs(entries) {
+		entry := entries[clientID]
+		entry = entry.Normalized()
+
+		uuid, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		name := entry.Account
+		if name == "" {
+			name = clientID
+		}
+
+		item := aegisEntry{
+			Type:   entry.Type,
+			UUID:   uuid,
+			Name:   name,
+			Issuer: entry.Issuer,
+			Info: aegisInfo{
+				Secret:  entry.Secret,
+				Algo:    entry.Algorithm,
+				Digits:  entry.Digits,
+				Counter: entry.Counter,
+			},
+		}
+		if !entry.IsCounterBased() {
+			item.Info.Period = entry.Period
+		}
+		backup.DB.Entries = append(backup.DB.Entries, item)
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/nordcloud/mfacli/pkg/codec"
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	FormatVault = "vault"
+	FormatURI   = "uri"
+	FormatAegis = "aegis"
+)
+
+var (
+	exporters = map[string]exporter{
+		FormatVault: {write: writeVault, binary: true},
+		FormatURI:   {write: writeURIs, plaintext: true},
+		FormatAegis: {write: writeAegis, plaintext: true},
+	}
+)
+
+type Options struct {
+	// Key is called to get the key an encrypted backup is written with
+	Key func() (*codec.Key, error)
+}
+
+type exporter struct {
+	write     func(entries map[string]secret.Entry, opts Options) ([]byte, error)
+	plaintext bool
+	binary    bool
+}
+
+// Formats returns the names of the supported export formats
+func Formats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// CheckFormat fails if the format is not supported, so that it can be checked before asking for any password
+func CheckFormat(format string) error {
+	if _, ok := exporters[format]; !ok {
+		return errors.Errorf("Unsupported export format: %s", format)
+	}
+	return nil
+}
+
+// IsPlaintext reports whether the secrets are written unencrypted in the given format
+func IsPlaintext(format string) bool {
+	return exporters[format].plaintext
+}
+
+// IsBinary reports whether the export in the given format is not printable text
+func IsBinary(format string) bool {
+	return exporters[format].binary
+}
+
+func Export(format string, entries map[string]secret.Entry, opts Options) ([]byte, error) {
+	if err := CheckFormat(format); err != nil {
+		return nil, err
+	}
+
+	return exporters[format].write(entries, opts)
+}
+
+// writeVault writes the backup in the vault format, so it can be used with the --vault flag directly
+func writeVault(entries map[string]secret.Entry, opts Options) ([]byte, error) {
+	key, err := opts.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.Encrypt(entries, key)
+}
+
+func writeURIs(entries map[string]secret.Entry, opts Options) ([]byte, error) {
+	var result []byte
+	for _, clientID := range sortedClientIDs(entries) {
+		entry := entries[clientID]
+		result = append(result, entry.URI(clientID)...)
+		result = append(result, '\n')
+	}
+	return result, nil
+}
+
+func sortedClientIDs(entries map[string]secret.Entry) []string {
+	clientIDs := make([]string, 0, len(entries))
+	for clientID := range entries {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Strings(clientIDs)
+	return clientIDs
+}
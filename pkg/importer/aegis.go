@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	aegisSlotPassword = 1
+)
+
+type aegisBackup struct {
+	Header struct {
+		Slots  []aegisSlot  `json:"slots"`
+		Params *aegisParams `json:"params"`
+	} `json:"header"`
+	DB json.RawMessage `json:"db"`
+}
+
+type aegisSlot struct {
+	Type      int         `json:"type"`
+	Key       string      `json:"key"`
+	KeyParams aegisParams `json:"key_params"`
+	N         int         `json:"n"`
+	R         int         `json:"r"`
+	P         int         `json:"p"`
+	Salt      string      `json:"salt"`
+}
+
+type aegisParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+type aegisDB struct {
+	Entries []aegisEntry `json:"entries"`
+}
+
+type aegisEntry struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Issuer string `json:"issuer"`
+	Info   struct {
+		Secret  string `json:"secret"`
+		Algo    string `json:"algo"`
+		Digits  int    `json:"digits"`
+		Period  uint   `json:"period"`
+		Counter uint64 `json:"counter"`
+	} `json:"info"`
+}
+
+// parseAegis reads the Aegis Authenticator vault export, either plain or encrypted with a password
+func parseAegis(data []byte, opts Options) ([]secret.Entry, error) {
+	var backup aegisBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, err
+	}
+
+	dbData := []byte(backup.DB)
+	if backup.Header.Params != nil {
+		var err error
+		if dbData, err = decryptAegisDB(&backup, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var db aegisDB
+	if err := json.Unmarshal(dbData, &db); err != nil {
+		return nil, err
+	}
+
+	entries := make([]secret.Entry, 0, len(db.Entries))
+	for _, item := range db.Entries {
+		if !isSupportedType(item.Type, item.Name) {
+			continue
+		}
+
+		entries = append(entries, secret.Entry{
+			Type:      item.Type,
+			Secret:    item.Info.Secret,
+			Algorithm: strings.ToUpper(item.Info.Algo),
+			Digits:    item.Info.Digits,
+			Period:    item.Info.Period,
+			Counter:   item.Info.Counter,
+			Issuer:    item.Issuer,
+			Account:   item.Name,
+		})
+	}
+
+	return entries, nil
+}
+
+func decryptAegisDB(backup *aegisBackup, opts Options) ([]byte, error) {
+	var encryptedDB string
+	if err := json.Unmarshal(backup.DB, &encryptedDB); err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedDB)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := opts.Password()
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := unlockAegisSlots(backup.Header.Slots, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return openAegis(masterKey, backup.Header.Params, ciphertext)
+}
+
+func unlockAegisSlots(slots []aegisSlot, password string) ([]byte, error) {
+	for _, slot := range slots {
+		if slot.Type != aegisSlotPassword {
+			continue
+		}
+
+		salt, err := hex.DecodeString(slot.Salt)
+		if err != nil {
+			return nil, err
+		}
+		key, err := scrypt.Key([]byte(password), salt, slot.N, slot.R, slot.P, 32)
+		if err != nil {
+			return nil, err
+		}
+		encryptedKey, err := hex.DecodeString(slot.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		masterKey, err := openAegis(key, &slot.KeyParams, encryptedKey)
+		if err == nil {
+			return masterKey, nil
+		}
+		if err != ErrInvalidBackupPassword {
+			return nil, err
+		}
+	}
+
+	return nil, ErrInvalidBackupPassword
+}
+
+func openAegis(key []byte, params *aegisParams, ciphertext []byte) ([]byte, error) {
+	nonce, err := hex.DecodeString(params.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := hex.DecodeString(params.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return openGCM(key, nonce, append(append([]byte{}, ciphertext...), tag...))
+}
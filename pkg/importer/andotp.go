@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	andOTPSaltSize      = 12
+	andOTPNonceSize     = 12
+	andOTPMaxIterations = 10000000
+)
+
+type andOTPEntry struct {
+	Secret    string `json:"secret"`
+	Issuer    string `json:"issuer"`
+	Label     string `json:"label"`
+	Digits    int    `json:"digits"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Period    uint   `json:"period"`
+	Counter   uint64 `json:"counter"`
+}
+
+// parseAndOTP reads the andOTP plain JSON backup or the encrypted (.json.aes) one
+func parseAndOTP(data []byte, opts Options) ([]secret.Entry, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '[' {
+		var err error
+		if data, err = decryptAndOTP(data, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []andOTPEntry
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	entries := make([]secret.Entry, 0, len(items))
+	for _, item := range items {
+		otpType := strings.ToLower(item.Type)
+		if !isSupportedType(otpType, item.Label) {
+			continue
+		}
+
+		entry := secret.Entry{
+			Type:      otpType,
+			Secret:    item.Secret,
+			Algorithm: strings.ToUpper(item.Algorithm),
+			Digits:    item.Digits,
+			Period:    item.Period,
+			Counter:   item.Counter,
+		}
+		entry.Issuer, entry.Account = secret.ParseLabel(item.Label)
+		if item.Issuer != "" {
+			entry.Issuer = item.Issuer
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// decryptAndOTP decrypts the backup written by andOTP: the current format starts with the number of PBKDF2
+// iterations and the salt, the old one used the SHA-256 sum of the password as the key
+func decryptAndOTP(data []byte, opts Options) ([]byte, error) {
+	password, err := opts.Password()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 4+andOTPSaltSize+andOTPNonceSize {
+		iterations := binary.BigEndian.Uint32(data[:4])
+		if iterations > 0 && iterations <= andOTPMaxIterations {
+			salt := data[4 : 4+andOTPSaltSize]
+			rest := data[4+andOTPSaltSize:]
+			key := pbkdf2.Key([]byte(password), salt, int(iterations), 32, sha1.New)
+			plaintext, err := openGCM(key, rest[:andOTPNonceSize], rest[andOTPNonceSize:])
+			if err == nil {
+				return plaintext, nil
+			}
+		}
+	}
+
+	if len(data) <= andOTPNonceSize {
+		return nil, errors.New("Backup file is too short")
+	}
+	key := sha256.Sum256([]byte(password))
+	return openGCM(key[:], data[:andOTPNonceSize], data[andOTPNonceSize:])
+}
@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"strings"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+type freeOTPBackup struct {
+	Tokens []freeOTPToken `json:"tokens"`
+}
+
+type freeOTPToken struct {
+	Algo      string `json:"algo"`
+	Counter   uint64 `json:"counter"`
+	Digits    int    `json:"digits"`
+	IssuerExt string `json:"issuerExt"`
+	Label     string `json:"label"`
+	Period    uint   `json:"period"`
+	Secret    []int  `json:"secret"` // signed Java bytes
+	Type      string `json:"type"`
+}
+
+// parseFreeOTP reads the JSON export of FreeOTP+. The backups of the original FreeOTP app are serialized
+// Java objects and are not supported.
+func parseFreeOTP(data []byte, opts Options) ([]secret.Entry, error) {
+	var backup freeOTPBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, err
+	}
+
+	entries := make([]secret.Entry, 0, len(backup.Tokens))
+	for _, token := range backup.Tokens {
+		otpType := strings.ToLower(token.Type)
+		if !isSupportedType(otpType, token.Label) {
+			continue
+		}
+
+		secretBytes := make([]byte, len(token.Secret))
+		for i, b := range token.Secret {
+			secretBytes[i] = byte(b)
+		}
+
+		entries = append(entries, secret.Entry{
+			Type:      otpType,
+			Secret:    base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secretBytes),
+			Algorithm: strings.ToUpper(token.Algo),
+			Digits:    token.Digits,
+			Period:    token.Period,
+			Counter:   token.Counter,
+			Issuer:    token.IssuerExt,
+			Account:   token.Label,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+// Field numbers and enum values of the MigrationPayload message of the Google Authenticator "Transfer accounts" export
+const (
+	migrationOtpParameters = 1
+	migrationBatchSize     = 3
+	migrationBatchIndex    = 4
+	migrationBatchID       = 5
+
+	otpSecret    = 1
+	otpName      = 2
+	otpIssuer    = 3
+	otpAlgorithm = 4
+	otpDigits    = 5
+	otpType      = 6
+	otpCounter   = 7
+
+	googleDigitsEight = 2
+	googleTypeHOTP    = 1
+)
+
+var (
+	googleAlgorithms = map[uint64]string{
+		0: secret.AlgorithmSHA1,
+		1: secret.AlgorithmSHA1,
+		2: secret.AlgorithmSHA256,
+		3: secret.AlgorithmSHA512,
+	}
+)
+
+type migrationBatch struct {
+	size  int
+	pages map[int]bool
+}
+
+// parseGoogleAuthenticator reads the accounts from the "Transfer accounts" QR codes. A source is either
+// an image file with the QR code, "qr-scan" to scan the QR code from the screen, or the decoded
+// otpauth-migration:// URI itself. All pages of a multi-page export must be passed.
+func parseGoogleAuthenticator(sources []string, opts Options) ([]secret.Entry, error) {
+	var entries []secret.Entry
+	batches := make(map[uint64]*migrationBatch)
+
+	for _, source := range sources {
+		raw, err := readMigrationURI(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", source)
+		}
+
+		pageEntries, err := parseMigrationURI(raw, batches)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", source)
+		}
+		entries = append(entries, pageEntries...)
+	}
+
+	for _, batch := range batches {
+		if len(batch.pages) != batch.size {
+			var missing []string
+			for i := 0; i < batch.size; i++ {
+				if !batch.pages[i] {
+					missing = append(missing, fmt.Sprint(i+1))
+				}
+			}
+			return nil, errors.Errorf("Incomplete export, missing QR code(s) %s of %d", strings.Join(missing, ", "), batch.size)
+		}
+	}
+
+	return entries, nil
+}
+
+func readMigrationURI(source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, secret.MigrationScheme+":"):
+		return source, nil
+	case source == "qr-scan":
+		return secret.ScanQR()
+	default:
+		return secret.DecodeQRFile(source)
+	}
+}
+
+func parseMigrationURI(raw string, batches map[uint64]*migrationBatch) ([]secret.Entry, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != secret.MigrationScheme {
+		return nil, errors.Errorf("Not a Google Authenticator export QR code")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(u.Query().Get("data"))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding migration data")
+	}
+
+	fields, err := decodeProtobuf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries               []secret.Entry
+		batchSize, batchIndex int
+		batchID               uint64
+	)
+	for _, field := range fields {
+		switch field.number {
+		case migrationOtpParameters:
+			entry, err := parseOtpParameters(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case migrationBatchSize:
+			batchSize = int(field.varint)
+		case migrationBatchIndex:
+			batchIndex = int(field.varint)
+		case migrationBatchID:
+			batchID = field.varint
+		}
+	}
+
+	if batchSize > 1 {
+		if batchIndex < 0 || batchIndex >= batchSize {
+			return nil, errors.Errorf("Invalid QR code number %d of %d", batchIndex+1, batchSize)
+		}
+		batch, ok := batches[batchID]
+		if !ok {
+			batch = &migrationBatch{size: batchSize, pages: make(map[int]bool)}
+			batches[batchID] = batch
+		}
+		if batch.pages[batchIndex] {
+			return nil, errors.Errorf("QR code %d of %d passed twice", batchIndex+1, batchSize)
+		}
+		batch.pages[batchIndex] = true
+	}
+
+	return entries, nil
+}
+
+func parseOtpParameters(data []byte) (secret.Entry, error) {
+	fields, err := decodeProtobuf(data)
+	if err != nil {
+		return secret.Entry{}, err
+	}
+
+	entry := secret.Entry{
+		Type:      secret.TypeTOTP,
+		Algorithm: secret.AlgorithmSHA1,
+		Digits:    secret.DefaultDigits,
+		Period:    secret.DefaultPeriod,
+	}
+	var name, issuer string
+	for _, field := range fields {
+		switch field.number {
+		case otpSecret:
+			entry.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(field.bytes)
+		case otpName:
+			name = string(field.bytes)
+		case otpIssuer:
+			issuer = string(field.bytes)
+		case otpAlgorithm:
+			algorithm, ok := googleAlgorithms[field.varint]
+			if !ok {
+				return secret.Entry{}, errors.Errorf("Unsupported algorithm of account %s", name)
+			}
+			entry.Algorithm = algorithm
+		case otpDigits:
+			if field.varint == googleDigitsEight {
+				entry.Digits = 8
+			}
+		case otpType:
+			if field.varint == googleTypeHOTP {
+				entry.Type = secret.TypeHOTP
+			}
+		case otpCounter:
+			entry.Counter = field.varint
+		}
+	}
+
+	entry.Issuer, entry.Account = secret.ParseLabel(name)
+	if issuer != "" {
+		entry.Issuer = issuer
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+func migrationURI(payload []byte) string {
+	return secret.MigrationScheme + "://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+}
+
+func migrationPage(index, size int, otpParameters ...[]byte) string {
+	var fields [][]byte
+	for _, params := range otpParameters {
+		fields = append(fields, protoBytes(migrationOtpParameters, params))
+	}
+	fields = append(fields,
+		protoVarint(migrationBatchSize, uint64(size)),
+		protoVarint(migrationBatchIndex, uint64(index)),
+		protoVarint(migrationBatchID, 42),
+	)
+	return migrationURI(protoMessage(fields...))
+}
+
+var (
+	// "Hello!" encoded in base32 is JBSWY3DPEE
+	totpParameters = protoMessage(
+		protoBytes(otpSecret, []byte("Hello!")),
+		protoBytes(otpName, []byte("Example:alice@example.com")),
+		protoBytes(otpIssuer, []byte("Example Inc")),
+		protoVarint(otpAlgorithm, 1),
+		protoVarint(otpDigits, 1),
+		protoVarint(otpType, 2),
+	)
+	hotpParameters = protoMessage(
+		protoBytes(otpSecret, []byte("Hello!")),
+		protoBytes(otpName, []byte("bob")),
+		protoVarint(otpAlgorithm, 2),
+		protoVarint(otpDigits, googleDigitsEight),
+		protoVarint(otpType, googleTypeHOTP),
+		protoVarint(otpCounter, 7),
+	)
+
+	totpEntry = secret.Entry{
+		Type:      secret.TypeTOTP,
+		Secret:    "JBSWY3DPEE",
+		Algorithm: secret.AlgorithmSHA1,
+		Digits:    6,
+		Period:    secret.DefaultPeriod,
+		Issuer:    "Example Inc",
+		Account:   "alice@example.com",
+	}
+	hotpEntry = secret.Entry{
+		Type:      secret.TypeHOTP,
+		Secret:    "JBSWY3DPEE",
+		Algorithm: secret.AlgorithmSHA256,
+		Digits:    8,
+		Period:    secret.DefaultPeriod,
+		Counter:   7,
+		Account:   "bob",
+	}
+)
+
+func TestParseMigrationURI(t *testing.T) {
+	entries, err := parseMigrationURI(migrationPage(0, 1, totpParameters, hotpParameters), make(map[uint64]*migrationBatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []secret.Entry{totpEntry, hotpEntry}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("parseMigrationURI() = %+v, want %+v", entries, expected)
+	}
+}
+
+func TestParseMigrationURIInvalid(t *testing.T) {
+	valid := protoMessage(protoBytes(migrationOtpParameters, totpParameters))
+
+	tests := map[string]string{
+		"truncated message":    migrationURI(valid[:len(valid)-3]),
+		"invalid length":       migrationURI(append(protoBytes(migrationOtpParameters, totpParameters)[:1], 0x7f, 1, 2)),
+		"truncated parameters": migrationURI(protoBytes(migrationOtpParameters, totpParameters[:len(totpParameters)-5])),
+	}
+
+	for name, uri := range tests {
+		if _, err := parseMigrationURI(uri, make(map[uint64]*migrationBatch)); err != errInvalidProtobuf {
+			t.Errorf("%s: parseMigrationURI() error = %v, want %v", name, err, errInvalidProtobuf)
+		}
+	}
+}
+
+func TestParseGoogleAuthenticatorPages(t *testing.T) {
+	first := migrationPage(0, 2, totpParameters)
+	second := migrationPage(1, 2, hotpParameters)
+
+	tests := []struct {
+		name    string
+		sources []string
+		entries []secret.Entry
+		err     string
+	}{
+		{name: "all pages", sources: []string{first, second}, entries: []secret.Entry{totpEntry, hotpEntry}},
+		{name: "any order", sources: []string{second, first}, entries: []secret.Entry{hotpEntry, totpEntry}},
+		{name: "missing page", sources: []string{first}, err: "missing QR code(s) 2 of 2"},
+		{name: "repeated page", sources: []string{first, first}, err: "QR code 1 of 2 passed twice"},
+		{name: "page out of range", sources: []string{first, migrationPage(5, 2, hotpParameters)}, err: "Invalid QR code number 6 of 2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := parseGoogleAuthenticator(test.sources, Options{})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("parseGoogleAuthenticator() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("parseGoogleAuthenticator() = %+v, want %+v", entries, test.entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	FormatGoogleAuthenticator = "google"
+	FormatAegis               = "aegis"
+	FormatAndOTP              = "andotp"
+	Format2FAS                = "2fas"
+	FormatFreeOTP             = "freeotp"
+)
+
+var (
+	ErrInvalidBackupPassword = errors.New("Invalid backup password")
+
+	parsers = map[string]parser{
+		FormatGoogleAuthenticator: parseGoogleAuthenticator,
+		FormatAegis:               fileParser(parseAegis),
+		FormatAndOTP:              fileParser(parseAndOTP),
+		Format2FAS:                fileParser(parse2FAS),
+		FormatFreeOTP:             fileParser(parseFreeOTP),
+	}
+)
+
+type Options struct {
+	// Password is called to read the password of an encrypted backup
+	Password func() (string, error)
+}
+
+type parser func(sources []string, opts Options) ([]secret.Entry, error)
+
+// Formats returns the names of the supported import formats
+func Formats() []string {
+	formats := make([]string, 0, len(parsers))
+	for format := range parsers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Parse reads the accounts from the sources in the given format
+func Parse(format string, sources []string, opts Options) ([]secret.Entry, error) {
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, errors.Errorf("Unsupported import format: %s", format)
+	}
+
+	entries, err := parse(sources, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if err := entries[i].Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid account %s", entries[i].SuggestedClientID())
+		}
+	}
+
+	return entries, nil
+}
+
+// fileParser turns a parser of a single backup file into a parser of all the files passed as sources
+func fileParser(parse func(data []byte, opts Options) ([]secret.Entry, error)) parser {
+	return func(sources []string, opts Options) ([]secret.Entry, error) {
+		var entries []secret.Entry
+		for _, source := range sources {
+			data, err := ioutil.ReadFile(source)
+			if err != nil {
+				return nil, err
+			}
+
+			fileEntries, err := parse(data, opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing %s", source)
+			}
+			entries = append(entries, fileEntries...)
+		}
+		return entries, nil
+	}
+}
+
+// isSupportedType tells whether the OTP type of an account can be imported, the accounts of the other types
+// (e.g. Steam) are skipped with a warning rather than failing the whole import
+func isSupportedType(otpType, account string) bool {
+	if otpType == secret.TypeTOTP || otpType == secret.TypeHOTP {
+		return true
+	}
+	log.WithField("type", otpType).WithField("account", account).Warn("Skipping an account of an unsupported type")
+	return false
+}
+
+// openGCM decrypts and authenticates the data sealed with AES-GCM, a failure is reported as an invalid password
+func openGCM(key, nonce, ciphertext []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCMWithNonceSize(c, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidBackupPassword
+	}
+	return plaintext, nil
+}
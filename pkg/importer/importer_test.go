@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	testPassword = "backup password"
+	// the encrypted fixtures use fixed salts and nonces, so that the tests are reproducible
+	testNonce = "0123456789ab"
+)
+
+var (
+	testSalt = []byte("salt of the fixtures")
+
+	expectedEntries = []secret.Entry{
+		{
+			Type:      secret.TypeTOTP,
+			Secret:    "JBSWY3DPEHPK3PXP",
+			Algorithm: secret.AlgorithmSHA1,
+			Digits:    6,
+			Period:    30,
+			Issuer:    "GitHub",
+			Account:   "alice",
+		},
+		{
+			Type:      secret.TypeHOTP,
+			Secret:    "GEZDGNBVGY3TQOJQ",
+			Algorithm: secret.AlgorithmSHA256,
+			Digits:    8,
+			Period:    30,
+			Counter:   5,
+			Issuer:    "Example",
+			Account:   "bob",
+		},
+	}
+)
+
+// sealGCM encrypts the plaintext the way the authenticator apps do, the tag is appended to the ciphertext
+func sealGCM(t *testing.T, key, plaintext []byte) []byte {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead.Seal(nil, []byte(testNonce), plaintext, nil)
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// aegisDBFixture contains an unsupported Steam entry, which must be skipped
+const aegisDBFixture = `{"version": 2, "entries": [
+	{"type": "totp", "name": "alice", "issuer": "GitHub", "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 6, "period": 30}},
+	{"type": "steam", "name": "gamer", "issuer": "Steam", "info": {"secret": "JBSWY3DPEHPK3PXP", "algo": "SHA1", "digits": 5, "period": 30}},
+	{"type": "hotp", "name": "bob", "issuer": "Example", "info": {"secret": "GEZDGNBVGY3TQOJQ", "algo": "SHA256", "digits": 8, "period": 30, "counter": 5}}
+]}`
+
+func plainAegis(t *testing.T) []byte {
+	return []byte(`{"version": 1, "header": {"slots": null, "params": null}, "db": ` + aegisDBFixture + `}`)
+}
+
+func encryptedAegis(t *testing.T) []byte {
+	masterKey := []byte("0123456789abcdef0123456789abcdef")
+	const n, r, p = 1024, 8, 1
+	slotKey, err := scrypt.Key([]byte(testPassword), testSalt, n, r, p, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split := func(sealed []byte) ([]byte, map[string]string) {
+		return sealed[:len(sealed)-16], map[string]string{
+			"nonce": hex.EncodeToString([]byte(testNonce)),
+			"tag":   hex.EncodeToString(sealed[len(sealed)-16:]),
+		}
+	}
+	encryptedKey, keyParams := split(sealGCM(t, slotKey, masterKey))
+	encryptedDB, dbParams := split(sealGCM(t, masterKey, []byte(aegisDBFixture)))
+
+	return mustJSON(t, map[string]interface{}{
+		"version": 1,
+		"header": map[string]interface{}{
+			"slots": []map[string]interface{}{
+				// a biometric slot cannot be unlocked with the password
+				{"type": 2, "key": hex.EncodeToString(encryptedKey), "key_params": keyParams},
+				{"type": aegisSlotPassword, "key": hex.EncodeToString(encryptedKey), "key_params": keyParams,
+					"n": n, "r": r, "p": p, "salt": hex.EncodeToString(testSalt)},
+			},
+			"params": dbParams,
+		},
+		"db": base64.StdEncoding.EncodeToString(encryptedDB),
+	})
+}
+
+const andOTPFixture = `[
+	{"secret": "JBSWY3DPEHPK3PXP", "issuer": "GitHub", "label": "alice", "digits": 6, "type": "TOTP", "algorithm": "SHA1", "period": 30},
+	{"secret": "JBSWY3DPEHPK3PXP", "issuer": "Steam", "label": "gamer", "digits": 5, "type": "STEAM", "algorithm": "SHA1", "period": 30},
+	{"secret": "GEZDGNBVGY3TQOJQ", "label": "Example:bob", "digits": 8, "type": "HOTP", "algorithm": "SHA256", "period": 30, "counter": 5}
+]`
+
+func encryptedAndOTP(t *testing.T) []byte {
+	const iterations = 1000
+	salt := testSalt[:andOTPSaltSize]
+	key := pbkdf2.Key([]byte(testPassword), salt, iterations, 32, sha1.New)
+
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, iterations)
+	data = append(data, salt...)
+	data = append(data, testNonce...)
+	return append(data, sealGCM(t, key, []byte(andOTPFixture))...)
+}
+
+func legacyAndOTP(t *testing.T) []byte {
+	key := sha256.Sum256([]byte(testPassword))
+	return append([]byte(testNonce), sealGCM(t, key[:], []byte(andOTPFixture))...)
+}
+
+const twoFASServicesFixture = `[
+	{"name": "GitHub", "secret": "JBSWY3DPEHPK3PXP", "otp": {"account": "alice", "digits": 6, "period": 30, "algorithm": "SHA1", "tokenType": "TOTP"}},
+	{"name": "Steam", "secret": "JBSWY3DPEHPK3PXP", "otp": {"account": "gamer", "digits": 5, "period": 30, "algorithm": "SHA1", "tokenType": "STEAM"}},
+	{"name": "Example", "secret": "GEZDGNBVGY3TQOJQ", "otp": {"label": "bob", "digits": 8, "period": 30, "algorithm": "SHA256", "tokenType": "HOTP", "counter": 5}}
+]`
+
+func plain2FAS(t *testing.T) []byte {
+	return []byte(`{"schemaVersion": 4, "services": ` + twoFASServicesFixture + `}`)
+}
+
+func encrypted2FAS(t *testing.T) []byte {
+	key := pbkdf2.Key([]byte(testPassword), testSalt, twoFASIterations, 32, sha256.New)
+	encrypted := fmt.Sprintf("%s:%s:%s",
+		base64.StdEncoding.EncodeToString(sealGCM(t, key, []byte(twoFASServicesFixture))),
+		base64.StdEncoding.EncodeToString(testSalt),
+		base64.StdEncoding.EncodeToString([]byte(testNonce)))
+	return mustJSON(t, map[string]interface{}{"schemaVersion": 4, "services": []interface{}{}, "servicesEncrypted": encrypted})
+}
+
+// the secrets are the bytes "Hello!\xde\xad\xbe\xef" and "1234567890" as signed Java bytes
+func plainFreeOTP(t *testing.T) []byte {
+	return []byte(`{"tokens": [
+		{"algo": "SHA1", "digits": 6, "issuerExt": "GitHub", "label": "alice", "period": 30, "type": "TOTP",
+			"secret": [72, 101, 108, 108, 111, 33, -34, -83, -66, -17]},
+		{"algo": "SHA1", "digits": 5, "issuerExt": "Steam", "label": "gamer", "period": 30, "type": "STEAM", "secret": [1, 2, 3]},
+		{"algo": "SHA256", "digits": 8, "issuerExt": "Example", "label": "bob", "period": 30, "type": "HOTP", "counter": 5,
+			"secret": [49, 50, 51, 52, 53, 54, 55, 56, 57, 48]}
+	]}`)
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func([]byte, Options) ([]secret.Entry, error)
+		fixture func(*testing.T) []byte
+		// encrypted fixtures are parsed with the right and with a wrong password
+		encrypted bool
+	}{
+		{name: "aegis plain", parse: parseAegis, fixture: plainAegis},
+		{name: "aegis encrypted", parse: parseAegis, fixture: encryptedAegis, encrypted: true},
+		{name: "andotp plain", parse: parseAndOTP, fixture: func(*testing.T) []byte { return []byte(andOTPFixture) }},
+		{name: "andotp encrypted", parse: parseAndOTP, fixture: encryptedAndOTP, encrypted: true},
+		{name: "andotp legacy encrypted", parse: parseAndOTP, fixture: legacyAndOTP, encrypted: true},
+		{name: "2fas plain", parse: parse2FAS, fixture: plain2FAS},
+		{name: "2fas encrypted", parse: parse2FAS, fixture: encrypted2FAS, encrypted: true},
+		{name: "freeotp", parse: parseFreeOTP, fixture: plainFreeOTP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.fixture(t)
+
+			passwordAsked := false
+			entries, err := test.parse(data, Options{Password: func() (string, error) {
+				passwordAsked = true
+				return testPassword, nil
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if passwordAsked != test.encrypted {
+				t.Errorf("password asked = %v, want %v", passwordAsked, test.encrypted)
+			}
+			if !reflect.DeepEqual(entries, expectedEntries) {
+				t.Errorf("entries = %+v, want %+v", entries, expectedEntries)
+			}
+
+			if !test.encrypted {
+				return
+			}
+			_, err = test.parse(data, Options{Password: func() (string, error) {
+				return "wrong password", nil
+			}})
+			if err != ErrInvalidBackupPassword {
+				t.Errorf("error with a wrong password = %v, want %v", err, ErrInvalidBackupPassword)
+			}
+		})
+	}
+}
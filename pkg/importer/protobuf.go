@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	wireVarint          = 0
+	wireFixed64         = 1
+	wireLengthDelimited = 2
+	wireFixed32         = 5
+)
+
+var (
+	errInvalidProtobuf = errors.New("Invalid protobuf message")
+)
+
+// protoField is a single field of a protobuf message, only the wire types used by the
+// Google Authenticator export are decoded
+type protoField struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProtobuf splits a protobuf message into its fields without a schema
+func decodeProtobuf(data []byte) ([]protoField, error) {
+	var fields []protoField
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errInvalidProtobuf
+		}
+		data = data[n:]
+
+		field := protoField{number: int(tag >> 3)}
+		switch tag & 0x7 {
+		case wireVarint:
+			field.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errInvalidProtobuf
+			}
+			data = data[n:]
+		case wireLengthDelimited:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, errInvalidProtobuf
+			}
+			field.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errInvalidProtobuf
+			}
+			field.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errInvalidProtobuf
+			}
+			field.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return nil, errInvalidProtobuf
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
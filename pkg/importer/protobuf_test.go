@@ -0,0 +1,71 @@
+package importer
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// protoVarint and protoBytes encode the fields of the messages used by the tests
+func protoVarint(number int, value uint64) []byte {
+	return append(uvarint(uint64(number)<<3|wireVarint), uvarint(value)...)
+}
+
+func protoBytes(number int, value []byte) []byte {
+	data := append(uvarint(uint64(number)<<3|wireLengthDelimited), uvarint(uint64(len(value)))...)
+	return append(data, value...)
+}
+
+func uvarint(value uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, value)]
+}
+
+func protoMessage(fields ...[]byte) []byte {
+	var data []byte
+	for _, field := range fields {
+		data = append(data, field...)
+	}
+	return data
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	fixed64 := []byte{1<<3 | wireFixed64, 1, 0, 0, 0, 0, 0, 0, 0}
+	fixed32 := []byte{2<<3 | wireFixed32, 2, 0, 0, 0}
+	data := protoMessage(protoVarint(1, 300), protoBytes(2, []byte("abc")), fixed64, fixed32, protoBytes(3, nil))
+
+	fields, err := decodeProtobuf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []protoField{
+		{number: 1, varint: 300},
+		{number: 2, bytes: []byte("abc")},
+		{number: 1, varint: 1},
+		{number: 2, varint: 2},
+		{number: 3, bytes: []byte{}},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("decodeProtobuf() = %+v, want %+v", fields, expected)
+	}
+}
+
+func TestDecodeProtobufInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated tag":       {0x80},
+		"truncated varint":    {1 << 3, 0x80},
+		"missing varint":      {1 << 3},
+		"length too long":     {1<<3 | wireLengthDelimited, 10, 'a', 'b', 'c'},
+		"huge length":         {1<<3 | wireLengthDelimited, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"truncated fixed64":   {1<<3 | wireFixed64, 1, 2, 3},
+		"truncated fixed32":   {1<<3 | wireFixed32, 1},
+		"unsupported wire 3":  {1<<3 | 3},
+		"truncated sub-field": protoBytes(1, []byte("abc"))[:3],
+	}
+
+	for name, data := range tests {
+		if _, err := decodeProtobuf(data); err != errInvalidProtobuf {
+			t.Errorf("%s: decodeProtobuf() error = %v, want %v", name, err, errInvalidProtobuf)
+		}
+	}
+}
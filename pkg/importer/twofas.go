@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	twoFASIterations = 10000
+)
+
+type twoFASBackup struct {
+	Services          []twoFASService `json:"services"`
+	ServicesEncrypted string          `json:"servicesEncrypted"`
+}
+
+type twoFASService struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	OTP    struct {
+		Label     string `json:"label"`
+		Account   string `json:"account"`
+		Issuer    string `json:"issuer"`
+		Digits    int    `json:"digits"`
+		Period    uint   `json:"period"`
+		Algorithm string `json:"algorithm"`
+		TokenType string `json:"tokenType"`
+		Counter   uint64 `json:"counter"`
+	} `json:"otp"`
+}
+
+// parse2FAS reads the 2FAS Authenticator backup, the services of an encrypted one are stored in
+// servicesEncrypted as "CIPHERTEXT:SALT:NONCE" (base64)
+func parse2FAS(data []byte, opts Options) ([]secret.Entry, error) {
+	var backup twoFASBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, err
+	}
+
+	services := backup.Services
+	if backup.ServicesEncrypted != "" {
+		plaintext, err := decrypt2FAS(backup.ServicesEncrypted, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(plaintext, &services); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]secret.Entry, 0, len(services))
+	for _, service := range services {
+		otpType := strings.ToLower(service.OTP.TokenType)
+		if otpType == "" {
+			otpType = secret.TypeTOTP
+		}
+		if !isSupportedType(otpType, service.Name) {
+			continue
+		}
+
+		entry := secret.Entry{
+			Type:      otpType,
+			Secret:    service.Secret,
+			Algorithm: strings.ToUpper(service.OTP.Algorithm),
+			Digits:    service.OTP.Digits,
+			Period:    service.OTP.Period,
+			Counter:   service.OTP.Counter,
+			Issuer:    service.OTP.Issuer,
+			Account:   service.OTP.Account,
+		}
+		if entry.Issuer == "" {
+			entry.Issuer = service.Name
+		}
+		if entry.Account == "" {
+			entry.Account = service.OTP.Label
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func decrypt2FAS(encrypted string, opts Options) ([]byte, error) {
+	parts := strings.Split(encrypted, ":")
+	if len(parts) != 3 {
+		return nil, errors.New("Invalid encrypted services")
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		var err error
+		if decoded[i], err = base64.StdEncoding.DecodeString(part); err != nil {
+			return nil, errors.Wrap(err, "decoding encrypted services")
+		}
+	}
+	ciphertext, salt, nonce := decoded[0], decoded[1], decoded[2]
+
+	password, err := opts.Password()
+	if err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, twoFASIterations, 32, sha256.New)
+	return openGCM(key, nonce, ciphertext)
+}
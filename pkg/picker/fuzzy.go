@@ -0,0 +1,182 @@
+package picker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	maxVisible = 10
+
+	keyCtrlC     = 3
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEnter     = '\r'
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// Fuzzy lets the user pick one of the items in the terminal. The items are filtered by the typed query, whose
+// characters must appear in the item in the same order. The selector is drawn on the output, so that
+// the standard output is left for the result of the command.
+func Fuzzy(input *os.File, output io.Writer, items []string) (string, error) {
+	state, err := terminal.MakeRaw(int(input.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer terminal.Restore(int(input.Fd()), state)
+
+	f := &fuzzy{output: output, items: items}
+	f.filter()
+	f.draw()
+	defer f.clear()
+
+	buf := make([]byte, 64)
+	for {
+		n, err := input.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		switch key := buf[:n]; {
+		case len(key) == 1 && (key[0] == keyCtrlC || key[0] == keyEscape):
+			return "", ErrCancelled
+		case len(key) == 1 && key[0] == keyEnter:
+			if len(f.matches) == 0 {
+				continue
+			}
+			return f.matches[f.selected], nil
+		case len(key) == 1 && (key[0] == keyBackspace || key[0] == keyCtrlH):
+			if len(f.query) > 0 {
+				_, size := utf8.DecodeLastRuneInString(f.query)
+				f.query = f.query[:len(f.query)-size]
+				f.filter()
+			}
+		case len(key) == 1 && key[0] == keyCtrlU:
+			f.query = ""
+			f.filter()
+		case string(key) == "\x1b[A" || string(key) == "\x1bOA" || (len(key) == 1 && key[0] == keyCtrlP):
+			if f.selected > 0 {
+				f.selected--
+			}
+		case string(key) == "\x1b[B" || string(key) == "\x1bOB" || (len(key) == 1 && key[0] == keyCtrlN):
+			if f.selected < len(f.matches)-1 {
+				f.selected++
+			}
+		case key[0] >= ' ' && key[0] != keyBackspace:
+			f.query += string(key)
+			f.filter()
+		}
+
+		f.clear()
+		f.draw()
+	}
+}
+
+type fuzzy struct {
+	output   io.Writer
+	items    []string
+	query    string
+	matches  []string
+	selected int
+	// drawn is the number of lines drawn below the prompt
+	drawn int
+}
+
+func (f *fuzzy) filter() {
+	type match struct {
+		item  string
+		score int
+	}
+
+	var matches []match
+	for _, item := range f.items {
+		if score, ok := fuzzyScore(f.query, item); ok {
+			matches = append(matches, match{item: item, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	f.matches = make([]string, len(matches))
+	for i, m := range matches {
+		f.matches[i] = m.item
+	}
+	f.selected = 0
+}
+
+// draw prints the prompt and the matches around the selected one, leaving the cursor after the query
+func (f *fuzzy) draw() {
+	first := 0
+	if f.selected >= maxVisible {
+		first = f.selected - maxVisible + 1
+	}
+	last := first + maxVisible
+	if last > len(f.matches) {
+		last = len(f.matches)
+	}
+
+	var b strings.Builder
+	for i := first; i < last; i++ {
+		if i == f.selected {
+			fmt.Fprintf(&b, "\r\n\x1b[7m> %s\x1b[0m", f.matches[i])
+		} else {
+			fmt.Fprintf(&b, "\r\n  %s", f.matches[i])
+		}
+	}
+	fmt.Fprintf(&b, "\r\n  %d/%d", len(f.matches), len(f.items))
+	f.drawn = last - first + 1
+
+	// go back to the prompt line
+	fmt.Fprintf(&b, "\x1b[%dA\r> %s", f.drawn, f.query)
+	fmt.Fprint(f.output, "\r> "+f.query+b.String())
+}
+
+func (f *fuzzy) clear() {
+	fmt.Fprint(f.output, "\r\x1b[J")
+}
+
+// fuzzyScore tells whether the characters of the query appear in the item in the same order, ignoring the case.
+// Consecutive characters and characters at the beginning of the words score higher.
+func fuzzyScore(query, item string) (int, bool) {
+	score := 0
+	itemRunes := []rune(strings.ToLower(item))
+	pos := 0
+	prev := -2
+
+	for _, q := range strings.ToLower(query) {
+		found := false
+		for ; pos < len(itemRunes); pos++ {
+			if itemRunes[pos] != q {
+				continue
+			}
+
+			score++
+			if pos == prev+1 {
+				score += 2
+			}
+			if pos == 0 || !unicode.IsLetter(itemRunes[pos-1]) && !unicode.IsDigit(itemRunes[pos-1]) {
+				score += 3
+			}
+			prev = pos
+			pos++
+			found = true
+			break
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	return score, true
+}
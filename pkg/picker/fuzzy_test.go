@@ -0,0 +1,62 @@
+package picker
+
+import (
+	"testing"
+)
+
+func TestFuzzyScoreMatching(t *testing.T) {
+	tests := []struct {
+		query   string
+		item    string
+		matches bool
+	}{
+		{query: "", item: "aws", matches: true},
+		{query: "aws", item: "aws", matches: true},
+		{query: "awp", item: "aws-prod", matches: true},
+		{query: "AWS", item: "aws-prod", matches: true},
+		{query: "aws", item: "AWS-Prod", matches: true},
+		{query: "ügy", item: "Ügyfél", matches: true},
+		// the characters must appear in the same order
+		{query: "swa", item: "aws", matches: false},
+		{query: "awss", item: "aws", matches: false},
+		{query: "x", item: "aws", matches: false},
+		{query: "aws", item: "", matches: false},
+	}
+
+	for _, test := range tests {
+		if _, ok := fuzzyScore(test.query, test.item); ok != test.matches {
+			t.Errorf("fuzzyScore(%q, %q) matches = %v, want %v", test.query, test.item, ok, test.matches)
+		}
+	}
+}
+
+func TestFuzzyScoreRanking(t *testing.T) {
+	tests := []struct {
+		query  string
+		better string
+		worse  string
+	}{
+		// consecutive characters score higher
+		{query: "prod", better: "aws-prod", worse: "pxrxoxd"},
+		// the beginning of the words scores higher
+		{query: "p", better: "aws-prod", worse: "aws-ops"},
+		{query: "s", better: "gcp-staging", worse: "aws"},
+		{query: "a", better: "aws", worse: "gcp-staging"},
+		// a digit doesn't start a new word
+		{query: "p", better: "aws_prod", worse: "aws1prod"},
+	}
+
+	for _, test := range tests {
+		better, ok := fuzzyScore(test.query, test.better)
+		if !ok {
+			t.Fatalf("fuzzyScore(%q, %q) doesn't match", test.query, test.better)
+		}
+		worse, ok := fuzzyScore(test.query, test.worse)
+		if !ok {
+			t.Fatalf("fuzzyScore(%q, %q) doesn't match", test.query, test.worse)
+		}
+		if better <= worse {
+			t.Errorf("fuzzyScore(%q) of %q = %d, want more than %d of %q", test.query, test.better, better, worse, test.worse)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package picker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LauncherDmenu = "dmenu"
+	LauncherRofi  = "rofi"
+	LauncherWofi  = "wofi"
+	LauncherFzf   = "fzf"
+
+	prompt = "mfacli"
+)
+
+var (
+	ErrCancelled = fmt.Errorf("Nothing has been picked")
+
+	launcherArgs = map[string][]string{
+		LauncherDmenu: {"-p", prompt},
+		LauncherRofi:  {"-dmenu", "-p", prompt},
+		LauncherWofi:  {"--dmenu", "--prompt", prompt},
+		LauncherFzf:   {"--prompt", prompt + "> "},
+	}
+)
+
+// Launchers returns the names of the launchers run with the arguments making them read the items
+// from the standard input
+func Launchers() []string {
+	return []string{LauncherDmenu, LauncherRofi, LauncherWofi, LauncherFzf}
+}
+
+// LauncherCommand returns the command line of a known launcher, any other launcher is split into
+// the command and its arguments
+func LauncherCommand(launcher string) []string {
+	if args, ok := launcherArgs[launcher]; ok {
+		return append([]string{launcher}, args...)
+	}
+	return strings.Fields(launcher)
+}
+
+// Launch pipes the items to the launcher command, which prints the picked one to its standard output
+func Launch(command []string, items []string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("No launcher command")
+	}
+
+	log.WithField("args", command[1:]).Debug("Running " + command[0])
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(items, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	var output bytes.Buffer
+	cmd.Stdout = &output
+
+	err := cmd.Run()
+	picked := strings.TrimSpace(output.String())
+	// the launchers exit with a non-zero code when they are dismissed
+	if _, ok := err.(*exec.ExitError); ok && picked == "" {
+		return "", ErrCancelled
+	}
+	if err != nil {
+		return "", err
+	}
+	if picked == "" {
+		return "", ErrCancelled
+	}
+	return picked, nil
+}
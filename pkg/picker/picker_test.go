@@ -0,0 +1,38 @@
+package picker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLauncherCommand(t *testing.T) {
+	tests := []struct {
+		launcher string
+		command  []string
+	}{
+		{launcher: LauncherDmenu, command: []string{"dmenu", "-p", "mfacli"}},
+		{launcher: LauncherRofi, command: []string{"rofi", "-dmenu", "-p", "mfacli"}},
+		{launcher: LauncherWofi, command: []string{"wofi", "--dmenu", "--prompt", "mfacli"}},
+		{launcher: LauncherFzf, command: []string{"fzf", "--prompt", "mfacli> "}},
+		// any other launcher is run as is, split into the arguments by whitespace
+		{launcher: "bemenu", command: []string{"bemenu"}},
+		{launcher: "rofi -dmenu -i", command: []string{"rofi", "-dmenu", "-i"}},
+		{launcher: "  fuzzel  --dmenu\t", command: []string{"fuzzel", "--dmenu"}},
+		{launcher: "", command: []string{}},
+	}
+
+	for _, test := range tests {
+		if command := LauncherCommand(test.launcher); !reflect.DeepEqual(command, test.command) {
+			t.Errorf("LauncherCommand(%q) = %q, want %q", test.launcher, command, test.command)
+		}
+	}
+}
+
+// the arguments of the known launchers must not be changed by the callers of LauncherCommand
+func TestLauncherCommandCopiesArguments(t *testing.T) {
+	command := LauncherCommand(LauncherRofi)
+	command[1] = "-show"
+	if command := LauncherCommand(LauncherRofi); command[1] != "-dmenu" {
+		t.Errorf("LauncherCommand(%q) = %q after modifying the previous result", LauncherRofi, command)
+	}
+}
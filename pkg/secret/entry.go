@@ -0,0 +1,162 @@
+package secret
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+
+	DefaultAlgorithm = AlgorithmSHA1
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+var (
+	algorithms = map[string]otp.Algorithm{
+		AlgorithmSHA1:   otp.AlgorithmSHA1,
+		AlgorithmSHA256: otp.AlgorithmSHA256,
+		AlgorithmSHA512: otp.AlgorithmSHA512,
+	}
+)
+
+// Entry is a single client stored in the vault together with its OTP parameters.
+// Zero values of the parameters mean the defaults (TOTP, SHA1, 6 digits, 30 seconds).
+type Entry struct {
+	Type      string `json:"type,omitempty"`
+	Secret    string `json:"secret"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Digits    int    `json:"digits,omitempty"`
+	Period    uint   `json:"period,omitempty"`
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"` // the counter of the next HOTP code
+}
+
+// UnmarshalJSON accepts a plain string as well, which is how the secrets were stored by the older versions
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var secret string
+	if err := json.Unmarshal(data, &secret); err == nil {
+		*e = Entry{Secret: secret}
+		return nil
+	}
+
+	type plainEntry Entry
+	return json.Unmarshal(data, (*plainEntry)(e))
+}
+
+func (e *Entry) Validate() error {
+	if e.Secret == "" {
+		return errors.Errorf("Secret must not be empty")
+	}
+	if e.Type != "" && e.Type != TypeTOTP && e.Type != TypeHOTP {
+		return errors.Errorf("Unsupported OTP type: %s", e.Type)
+	}
+	if _, err := e.otpAlgorithm(); err != nil {
+		return err
+	}
+	if e.Digits != 0 && (e.Digits < 6 || e.Digits > 10) {
+		return errors.Errorf("Invalid number of digits: %d", e.Digits)
+	}
+	return nil
+}
+
+// IsCounterBased reports whether the entry is an HOTP one, in which case the counter must be advanced after
+// each generated code
+func (e *Entry) IsCounterBased() bool {
+	return e.Type == TypeHOTP
+}
+
+// GenerateCode returns the TOTP code for the given time, or the HOTP code for the current counter
+func (e *Entry) GenerateCode(t time.Time) (string, error) {
+	algorithm, err := e.otpAlgorithm()
+	if err != nil {
+		return "", err
+	}
+
+	if e.IsCounterBased() {
+		return hotp.GenerateCodeCustom(e.Secret, e.Counter, hotp.ValidateOpts{
+			Digits:    otp.Digits(e.digits()),
+			Algorithm: algorithm,
+		})
+	}
+
+	return totp.GenerateCodeCustom(e.Secret, t, totp.ValidateOpts{
+		Period:    e.period(),
+		Digits:    otp.Digits(e.digits()),
+		Algorithm: algorithm,
+	})
+}
+
+// ExpiresAt returns the end of the period the TOTP code generated for the given time is valid in,
+// or the zero time for HOTP codes which stay valid until they are used
+func (e *Entry) ExpiresAt(t time.Time) time.Time {
+	if e.IsCounterBased() {
+		return time.Time{}
+	}
+
+	period := int64(e.period())
+	return time.Unix((t.Unix()/period+1)*period, 0)
+}
+
+// Normalized returns a copy of the entry with all the parameters set explicitly
+func (e *Entry) Normalized() Entry {
+	result := *e
+	result.Type = e.otpType()
+	result.Algorithm = e.algorithmName()
+	result.Digits = e.digits()
+	result.Period = e.period()
+	return result
+}
+
+func (e *Entry) otpType() string {
+	if e.Type == "" {
+		return TypeTOTP
+	}
+	return e.Type
+}
+
+func (e *Entry) algorithmName() string {
+	if e.Algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return strings.ToUpper(e.Algorithm)
+}
+
+func (e *Entry) digits() int {
+	if e.Digits == 0 {
+		return DefaultDigits
+	}
+	return e.Digits
+}
+
+func (e *Entry) period() uint {
+	if e.Period == 0 {
+		return DefaultPeriod
+	}
+	return e.Period
+}
+
+func (e *Entry) otpAlgorithm() (otp.Algorithm, error) {
+	if e.Algorithm == "" {
+		return otp.AlgorithmSHA1, nil
+	}
+
+	algorithm, ok := algorithms[strings.ToUpper(e.Algorithm)]
+	if !ok {
+		return 0, errors.Errorf("Unsupported algorithm: %s", e.Algorithm)
+	}
+	return algorithm, nil
+}
@@ -5,7 +5,6 @@ import (
 	"image"
 	_ "image/png"
 	"io/ioutil"
-	"net/url"
 	"os"
 	"os/exec"
 	"strings"
@@ -19,6 +18,7 @@ import (
 type SecretValue struct {
 	value string
 	isSet bool
+	entry *Entry
 }
 
 func (s *SecretValue) String() string {
@@ -77,6 +77,11 @@ func (s *SecretValue) IsSet() bool {
 	return s.isSet
 }
 
+// Entry returns the client metadata if the secret has been imported from an otpauth:// URI, nil otherwise
+func (s *SecretValue) Entry() *Entry {
+	return s.entry
+}
+
 func (s *SecretValue) setFromFile(filename string) error {
 	body, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -96,63 +101,82 @@ func (s *SecretValue) setFromEnv(env string) error {
 }
 
 func (s *SecretValue) setFromQRScan() error {
-	tmpFile, err := ioutil.TempFile("", "mfacli-img*.png")
+	raw, err := ScanQR()
+	if err != nil {
+		return err
+	}
+	return s.setFromDecodedQR(raw)
+}
+
+func (s *SecretValue) setFromQRFile(filename string) error {
+	raw, err := DecodeQRFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.setFromDecodedQR(raw)
+}
+
+func (s *SecretValue) setFromDecodedQR(raw string) error {
+	if strings.HasPrefix(raw, MigrationScheme+":") {
+		return errors.Errorf("The QR code is a Google Authenticator export, use the import command instead")
+	}
+	if !strings.HasPrefix(raw, otpauthScheme+":") {
+		s.value = raw
+		return nil
+	}
+
+	entry, err := ParseURI(raw)
 	if err != nil {
 		return err
 	}
+
+	s.value = entry.Secret
+	s.entry = entry
+	return nil
+}
+
+// ScanQR captures the screen (or a selected part of it) and returns the content of the QR code found there
+func ScanQR() (string, error) {
+	tmpFile, err := ioutil.TempFile("", "mfacli-img*.png")
+	if err != nil {
+		return "", err
+	}
 	filename := tmpFile.Name()
+	tmpFile.Close()
 	defer os.Remove(filename)
 
 	if err := exec.Command("import", filename).Run(); err != nil {
-		return err
+		return "", err
 	}
 
-	return s.setFromQRFile(filename)
+	return DecodeQRFile(filename)
 }
 
-func (s *SecretValue) setFromQRFile(filename string) error {
+// DecodeQRFile returns the content of the QR code in the image file
+func DecodeQRFile(filename string) (string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer file.Close()
 
 	img, _, err := image.Decode(file)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	qrReader := qrcode.NewQRCodeReader()
 	res, err := qrReader.DecodeWithoutHints(bmp)
 	if err != nil {
-		return errors.Wrap(err, "failed to decode qr code")
-	}
-
-	s.value = tryParseUrl(res.String())
-	return nil
-}
-
-func tryParseUrl(raw string) string {
-	url, err := url.Parse(raw)
-	if err != nil {
-		return raw
-	}
-
-	if url.Scheme != "otpauth" {
-		return raw
-	}
-
-	secret := url.Query().Get("secret")
-	if secret == "" {
-		return raw
+		return "", errors.Wrap(err, "failed to decode qr code")
 	}
 
-	return secret
+	return res.String(), nil
 }
 
 func readSecret(prompt string) (string, error) {
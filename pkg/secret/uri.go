@@ -0,0 +1,117 @@
+package secret
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	otpauthScheme = "otpauth"
+
+	MigrationScheme = "otpauth-migration"
+)
+
+// ParseURI parses an otpauth:// URI in the Key Uri Format used by Google Authenticator and most providers:
+//
+//	otpauth://TYPE/[ISSUER:]ACCOUNT?secret=SECRET&issuer=ISSUER&algorithm=SHA1&digits=6&period=30&counter=0
+func ParseURI(raw string) (*Entry, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing otpauth URI")
+	}
+	if u.Scheme != otpauthScheme {
+		return nil, errors.Errorf("Not an %s URI", otpauthScheme)
+	}
+
+	entry := &Entry{
+		Type: strings.ToLower(u.Host),
+	}
+	entry.Issuer, entry.Account = ParseLabel(strings.TrimPrefix(u.Path, "/"))
+
+	query := u.Query()
+	entry.Secret = query.Get("secret")
+	if issuer := query.Get("issuer"); issuer != "" {
+		entry.Issuer = issuer
+	}
+	entry.Algorithm = strings.ToUpper(query.Get("algorithm"))
+
+	if digits := query.Get("digits"); digits != "" {
+		if entry.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, errors.Errorf("Invalid digits in otpauth URI: %s", digits)
+		}
+	}
+	if period := query.Get("period"); period != "" {
+		value, err := strconv.ParseUint(period, 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("Invalid period in otpauth URI: %s", period)
+		}
+		entry.Period = uint(value)
+	}
+	if counter := query.Get("counter"); counter != "" {
+		if entry.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return nil, errors.Errorf("Invalid counter in otpauth URI: %s", counter)
+		}
+	}
+
+	if err := entry.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth URI")
+	}
+
+	return entry, nil
+}
+
+// URI returns the otpauth:// URI of the entry, the client ID is used as the label if the account name is not known
+func (e *Entry) URI(clientID string) string {
+	label := e.Account
+	if label == "" {
+		label = clientID
+	}
+	if e.Issuer != "" && e.Account != "" {
+		label = e.Issuer + ":" + label
+	}
+
+	query := url.Values{}
+	query.Set("secret", e.Secret)
+	if e.Issuer != "" {
+		query.Set("issuer", e.Issuer)
+	}
+	query.Set("algorithm", e.algorithmName())
+	query.Set("digits", strconv.Itoa(e.digits()))
+	if e.IsCounterBased() {
+		query.Set("counter", strconv.FormatUint(e.Counter, 10))
+	} else {
+		query.Set("period", strconv.FormatUint(uint64(e.period()), 10))
+	}
+
+	u := url.URL{
+		Scheme:   otpauthScheme,
+		Host:     e.otpType(),
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// ParseLabel splits an "ISSUER:ACCOUNT" label into its parts. The issuer is optional.
+func ParseLabel(label string) (issuer, account string) {
+	if i := strings.Index(label, ":"); i >= 0 {
+		issuer = strings.TrimSpace(label[:i])
+		label = label[i+1:]
+	}
+	return issuer, strings.TrimSpace(label)
+}
+
+// SuggestedClientID returns a client ID built from the issuer and the account name, if they are known
+func (e *Entry) SuggestedClientID() string {
+	switch {
+	case e.Issuer != "" && e.Account != "":
+		return e.Issuer + ":" + e.Account
+	case e.Account != "":
+		return e.Account
+	default:
+		return e.Issuer
+	}
+}
@@ -0,0 +1,41 @@
+package vault
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const (
+	// listenFDsStart is the first file descriptor passed by systemd
+	listenFDsStart = 3
+)
+
+// activationListener returns the socket passed by systemd (see sd_listen_fds(3)), or nil if the server
+// hasn't been socket-activated
+func activationListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid LISTEN_FDS: %s", os.Getenv("LISTEN_FDS"))
+	}
+	if fds != 1 {
+		return nil, fmt.Errorf("Expected a single socket passed by systemd, got %d", fds)
+	}
+
+	// the variables must not be inherited by the processes started by the server
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	syscall.CloseOnExec(listenFDsStart)
+	file := os.NewFile(listenFDsStart, "systemd-socket")
+	defer file.Close()
+
+	return net.FileListener(file)
+}
@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/nordcloud/mfacli/pkg/secret"
+)
+
+const (
+	ChangePut    = "put"
+	ChangeRemove = "remove"
+	ChangeRename = "rename"
+)
+
+var (
+	ErrClientExists = fmt.Errorf("Client ID already exists")
+)
+
+// Change is a single modification of the vault entries. The changes passed to Vault.ApplyChanges are applied
+// in order, atomically, to the current state of the vault rather than to a snapshot read by the client.
+type Change struct {
+	Op       string `json:"op"`
+	ClientID string `json:"client_id"`
+	// Entry is the new entry of the put operation
+	Entry secret.Entry `json:"entry"`
+	// NewClientID is the target of the rename operation
+	NewClientID string `json:"new_client_id,omitempty"`
+	// Overwrite allows the put and rename operations to replace an existing entry
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+func PutEntry(clientID string, entry secret.Entry, overwrite bool) Change {
+	return Change{Op: ChangePut, ClientID: clientID, Entry: entry, Overwrite: overwrite}
+}
+
+func RemoveEntry(clientID string) Change {
+	return Change{Op: ChangeRemove, ClientID: clientID}
+}
+
+func RenameEntry(clientID, newClientID string, overwrite bool) Change {
+	return Change{Op: ChangeRename, ClientID: clientID, NewClientID: newClientID, Overwrite: overwrite}
+}
+
+// applyChanges returns a copy of the secrets with the changes applied, the secrets are left untouched on error
+func applyChanges(secrets map[string]secret.Entry, changes []Change) (map[string]secret.Entry, error) {
+	result := make(map[string]secret.Entry, len(secrets))
+	for clientID, entry := range secrets {
+		result[clientID] = entry
+	}
+
+	for _, change := range changes {
+		switch change.Op {
+		case ChangePut:
+			if err := change.Entry.Validate(); err != nil {
+				return nil, err
+			}
+			if _, ok := result[change.ClientID]; ok && !change.Overwrite {
+				return nil, ErrClientExists
+			}
+			result[change.ClientID] = change.Entry
+		case ChangeRemove:
+			delete(result, change.ClientID)
+		case ChangeRename:
+			entry, ok := result[change.ClientID]
+			if !ok {
+				return nil, ErrClientNotFound
+			}
+			if _, ok := result[change.NewClientID]; ok && !change.Overwrite && change.NewClientID != change.ClientID {
+				return nil, ErrClientExists
+			}
+			delete(result, change.ClientID)
+			result[change.NewClientID] = entry
+		default:
+			return nil, fmt.Errorf("Unsupported change: %s", change.Op)
+		}
+	}
+
+	return result, nil
+}
@@ -1,54 +1,244 @@
 package vault
 
 import (
+	"crypto/sha256"
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 
 	"github.com/nordcloud/mfacli/config"
 	"github.com/nordcloud/mfacli/pkg/codec"
 	"github.com/nordcloud/mfacli/pkg/password"
+	"github.com/nordcloud/mfacli/pkg/secret"
 )
 
 type localVault struct {
-	secrets map[string]string
-	encKey  []byte
+	secrets map[string]secret.Entry
+	encKey  *codec.Key
 	path    string
+	// digest is the checksum of the vault file as it was last read or written, it tells whether
+	// the file has been changed by another process since
+	digest [sha256.Size]byte
+	// revision is increased whenever the secrets change, it allows rejecting changes based on stale secrets
+	revision uint64
+	// upgraded is set when the vault was opened with a legacy key and encKey has been replaced with a new one
+	upgraded bool
 }
 
-func (v *localVault) GetSecrets() (map[string]string, error) {
-	secrets := make(map[string]string, len(v.secrets))
+func (v *localVault) GetSecrets() (map[string]secret.Entry, error) {
+	secrets := make(map[string]secret.Entry, len(v.secrets))
 	for k, v := range v.secrets {
 		secrets[k] = v
 	}
 	return secrets, nil
 }
 
-func (v *localVault) ModifySecrets(modify func(map[string]string) error) error {
-	if err := modify(v.secrets); err != nil {
+func (v *localVault) ListClients() ([]string, error) {
+	clientIDs := make([]string, 0, len(v.secrets))
+	for clientID := range v.secrets {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Strings(clientIDs)
+	return clientIDs, nil
+}
+
+// replaceSecrets stores the whole set of secrets. It fails with ErrVaultChanged if the vault file has been
+// changed since the given revision was read, as the secrets might be based on an outdated snapshot.
+func (v *localVault) replaceSecrets(secrets map[string]secret.Entry, revision uint64) error {
+	return v.update(func(map[string]secret.Entry) (map[string]secret.Entry, error) {
+		if v.revision != revision {
+			return nil, ErrVaultChanged
+		}
+		return secrets, nil
+	})
+}
+
+func (v *localVault) ApplyChanges(changes ...Change) error {
+	return v.update(func(secrets map[string]secret.Entry) (map[string]secret.Entry, error) {
+		return applyChanges(secrets, changes)
+	})
+}
+
+func (v *localVault) GenerateCode(clientID string, next bool) (Code, error) {
+	t := time.Now()
+	entry, ok := v.secrets[clientID]
+	if !ok {
+		return Code{}, ErrClientNotFound
+	}
+	if !entry.IsCounterBased() {
+		if next {
+			t = entry.ExpiresAt(t)
+		}
+		return generateCode(entry, t)
+	}
+
+	// the counter is read again with the vault file locked, so that concurrent processes never reuse it
+	var code Code
+	err := v.update(func(secrets map[string]secret.Entry) (map[string]secret.Entry, error) {
+		entry, ok := secrets[clientID]
+		if !ok {
+			return nil, ErrClientNotFound
+		}
+
+		var err error
+		if code, err = generateCode(entry, t); err != nil {
+			return nil, err
+		}
+		entry.Counter++
+		secrets[clientID] = entry
+		return secrets, nil
+	})
+	if err != nil {
+		return Code{}, err
+	}
+	return code, nil
+}
+
+func generateCode(entry secret.Entry, t time.Time) (Code, error) {
+	value, err := entry.GenerateCode(t)
+	if err != nil {
+		return Code{}, err
+	}
+	code := Code{Value: value, ExpiresAt: entry.ExpiresAt(t)}
+	if !entry.IsCounterBased() {
+		code.Period = entry.Normalized().Period
+	}
+	return code, nil
+}
+
+// update modifies a copy of the secrets and saves the result. The vault file is locked meanwhile and read
+// again first if it has been changed, so that the changes of concurrent processes are never overwritten.
+func (v *localVault) update(modify func(map[string]secret.Entry) (map[string]secret.Entry, error)) error {
+	unlock, err := lockVaultFile(v.path)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	return v.save()
+	if _, err := v.refresh(); err != nil {
+		return err
+	}
+
+	current, err := v.GetSecrets()
+	if err != nil {
+		return err
+	}
+	secrets, err := modify(current)
+	if err != nil {
+		return err
+	}
+
+	oldSecrets := v.secrets
+	v.secrets = secrets
+	if err := v.save(); err != nil {
+		v.secrets = oldSecrets
+		return err
+	}
+	return nil
+}
+
+// refresh decrypts the vault file again if it has been changed by another process since it was last read
+// or written, and tells whether it has. It fails with ErrVaultChanged if the file cannot be decrypted
+// with the current key anymore (e.g. the password has been changed).
+func (v *localVault) refresh() (bool, error) {
+	data, err := ioutil.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		log.WithField("vault_path", v.path).Warn("Vault file has been removed, it will be recreated on the next save")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	digest := sha256.Sum256(data)
+	if digest == v.digest {
+		return false, nil
+	}
+
+	secrets, err := codec.Decrypt(data, v.encKey)
+	if err != nil {
+		log.WithError(err).Warn("Failed to decrypt the changed vault file")
+		return false, ErrVaultChanged
+	}
+
+	v.secrets = secrets
+	v.digest = digest
+	v.revision++
+	return true, nil
 }
 
+// save writes the vault to a temporary file which then replaces the vault file,
+// so that the vault is never left half-written
 func (v *localVault) save() error {
 	vaultData, err := codec.Encrypt(v.secrets, v.encKey)
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(v.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+
+	file, err := ioutil.TempFile(filepath.Dir(v.path), "."+filepath.Base(v.path)+".*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
+
+	if err := writeAndClose(file, vaultData); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), v.path); err != nil {
+		return err
+	}
 
-	_, err = file.Write(vaultData)
-	return err
+	v.digest = sha256.Sum256(vaultData)
+	v.revision++
+	v.upgraded = false
+	return nil
+}
+
+func (v *localVault) changeKey(key *codec.Key) error {
+	unlock, err := lockVaultFile(v.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := v.refresh(); err != nil {
+		return err
+	}
+
+	oldKey := v.encKey
+	v.encKey = key
+	if err := v.save(); err != nil {
+		v.encKey = oldKey
+		return err
+	}
+	return nil
+}
+
+// lockVaultFile takes an exclusive lock shared by all the processes modifying the vault, until the returned
+// function is called. A separate lock file is used, as the vault file itself is replaced on every save.
+func lockVaultFile(path string) (func(), error) {
+	lockPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// closing the file releases the lock
+	return func() { file.Close() }, nil
 }
 
 func openLocal(cfg *config.Config) (*localVault, error) {
-	vault, err := readVaultFile(cfg)
+	vault, err := readVaultFile(cfg, "Password")
 	if err == nil {
 		return vault, nil
 	}
@@ -61,9 +251,14 @@ func openLocal(cfg *config.Config) (*localVault, error) {
 		return nil, err
 	}
 
+	key, err := NewEncKey(cfg, pwd)
+	if err != nil {
+		return nil, err
+	}
+
 	vault = &localVault{
-		secrets: make(map[string]string),
-		encKey:  codec.BuildEncKey(pwd),
+		secrets: make(map[string]secret.Entry),
+		encKey:  key,
 		path:    cfg.VaultPath,
 	}
 	if err := vault.save(); err != nil {
@@ -73,7 +268,7 @@ func openLocal(cfg *config.Config) (*localVault, error) {
 	return vault, nil
 }
 
-func readVaultFile(cfg *config.Config) (*localVault, error) {
+func readVaultFile(cfg *config.Config, prompt string) (*localVault, error) {
 	file, err := os.Open(cfg.VaultPath)
 	if err != nil {
 		return nil, err
@@ -85,25 +280,81 @@ func readVaultFile(cfg *config.Config) (*localVault, error) {
 		return nil, err
 	}
 
-	pwd, err := password.ReadPassword(cfg, "Password")
+	params, err := codec.ReadKDFParams(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pwd, err := password.ReadPassword(cfg, prompt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := codec.BuildEncKey(pwd, params)
 	if err != nil {
 		return nil, err
 	}
-	secrets, err := codec.Decrypt(data, codec.BuildEncKey(pwd))
+	secrets, err := codec.Decrypt(data, key)
 	for errors.Is(err, codec.ErrInvalidPassword) {
 		pwd, err = password.ReadPassword(cfg, "Invalid password. Try again")
 		if err != nil {
 			return nil, err
 		}
-		secrets, err = codec.Decrypt(data, codec.BuildEncKey(pwd))
+		key, err = codec.BuildEncKey(pwd, params)
+		if err != nil {
+			return nil, err
+		}
+		secrets, err = codec.Decrypt(data, key)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &localVault{
+	vault := &localVault{
 		secrets: secrets,
-		encKey:  codec.BuildEncKey(pwd),
+		encKey:  key,
 		path:    file.Name(),
-	}, nil
+		digest:  sha256.Sum256(data),
+	}
+
+	if key.IsLegacy() {
+		log.WithField("kdf", key.KDF.Algorithm).Debug("Vault uses a legacy key, it will be upgraded on the next save")
+		if vault.encKey, err = NewEncKey(cfg, pwd); err != nil {
+			return nil, err
+		}
+		vault.upgraded = true
+	}
+
+	return vault, nil
+}
+
+// NewEncKey derives a key from the password with a fresh salt and the KDF parameters set in the config
+func NewEncKey(cfg *config.Config, pwd string) (*codec.Key, error) {
+	params, err := codec.NewKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.KDFTime != 0 {
+		params.Time = cfg.KDFTime
+	}
+	if cfg.KDFMemory != 0 {
+		params.Memory = cfg.KDFMemory
+	}
+
+	return codec.BuildEncKey(pwd, params)
+}
+
+func writeAndClose(file *os.File, data []byte) error {
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
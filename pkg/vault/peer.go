@@ -0,0 +1,172 @@
+package vault
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/audit"
+)
+
+var (
+	errPeerCredUnsupported = fmt.Errorf("Peer credentials are not supported on this platform")
+
+	ErrExecutableNotAllowed = fmt.Errorf("The executable is not allowed to request codes")
+)
+
+type peerCred struct {
+	UID        int
+	PID        int
+	Executable string
+}
+
+// peerServer serves the requests of a single connection, so that they can be checked against the peer process
+type peerServer struct {
+	*VaultServer
+	peer *peerCred
+}
+
+func (p *peerServer) GenerateCode(args GenerateCodeArgs, code *Code) error {
+	if !p.isAllowed() {
+		logger := log.NewEntry(log.StandardLogger())
+		if p.peer != nil {
+			logger = logger.WithField("executable", p.peer.Executable).WithField("pid", p.peer.PID)
+		}
+		logger.Warn("Rejected a code request")
+		p.audit(audit.ActionGenerateCode, args.ClientID, ErrExecutableNotAllowed)
+		return ErrExecutableNotAllowed
+	}
+
+	err := p.VaultServer.GenerateCode(args, code)
+	p.audit(audit.ActionGenerateCode, args.ClientID, err)
+	return err
+}
+
+func (p *peerServer) GetSecrets(args GetSecretsArgs, snapshot *Snapshot) error {
+	err := p.VaultServer.GetSecrets(args, snapshot)
+	p.audit(audit.ActionGetSecrets, "", err)
+	return err
+}
+
+func (p *peerServer) StoreSecrets(args StoreSecretsArgs, output *struct{}) error {
+	err := p.VaultServer.StoreSecrets(args, output)
+	p.audit(audit.ActionStoreSecrets, "", err)
+	return err
+}
+
+// ApplyChanges logs every change separately, named after its operation
+func (p *peerServer) ApplyChanges(args ApplyChangesArgs, output *struct{}) error {
+	err := p.VaultServer.ApplyChanges(args, output)
+	for _, change := range args.Changes {
+		p.logEvent(audit.Event{Action: change.Op, ClientID: change.ClientID, NewClientID: change.NewClientID}, err)
+	}
+	return err
+}
+
+func (p *peerServer) ChangeKey(args ChangeKeyArgs, output *struct{}) error {
+	err := p.VaultServer.ChangeKey(args, output)
+	p.audit(audit.ActionChangeKey, "", err)
+	return err
+}
+
+func (p *peerServer) audit(action, clientID string, err error) {
+	p.logEvent(audit.Event{Action: action, ClientID: clientID}, err)
+}
+
+// logEvent completes the event with the outcome and the peer process and writes it to the audit log
+func (p *peerServer) logEvent(event audit.Event, err error) {
+	if p.auditLog == nil {
+		return
+	}
+
+	event.Outcome = audit.OutcomeOK
+	if err != nil {
+		event.Outcome = audit.OutcomeError
+		event.Error = err.Error()
+	}
+	if p.peer != nil {
+		event.PID = p.peer.PID
+		event.Executable = p.peer.Executable
+	}
+
+	if err := p.auditLog.Log(event); err != nil {
+		log.WithError(err).Error("Failed to write the audit log")
+	}
+}
+
+// ValidateServerConfig checks that the server options are supported on the current platform
+func ValidateServerConfig(cfg *config.Config) error {
+	if len(cfg.AllowedExecutables) > 0 && !peerCredSupported {
+		return fmt.Errorf("--%s is not supported on this platform, the executable of the clients cannot be checked", config.FlagAllowedExecutable)
+	}
+	return nil
+}
+
+func (p *peerServer) isAllowed() bool {
+	if len(p.allowedExecutables) == 0 {
+		return true
+	}
+	if p.peer == nil {
+		return false
+	}
+	return p.allowedExecutables[p.peer.Executable]
+}
+
+// accept serves the connections of the peers running as the same user
+func (s *VaultServer) accept() {
+	for {
+		conn, err := s.lis.Accept()
+		if err != nil {
+			log.WithError(err).Info("Stopped accepting connections")
+			return
+		}
+
+		peer, err := getPeerCred(conn)
+		if err == errPeerCredUnsupported {
+			peer = nil
+		} else if err != nil {
+			log.WithError(err).Warn("Failed to read peer credentials, closing connection")
+			conn.Close()
+			continue
+		} else if peer.UID != os.Getuid() {
+			log.WithField("uid", peer.UID).WithField("pid", peer.PID).Warn("Rejected a connection from another user")
+			conn.Close()
+			continue
+		}
+
+		logger := log.NewEntry(log.StandardLogger())
+		if peer != nil {
+			logger = logger.WithField("pid", peer.PID).WithField("executable", peer.Executable)
+		}
+		logger.Info("Accepted a connection")
+
+		server := rpc.NewServer()
+		if err := server.RegisterName(serverName, &peerServer{VaultServer: s, peer: peer}); err != nil {
+			logger.WithError(err).Error("Failed to register the RPC server")
+			conn.Close()
+			continue
+		}
+		go serveConn(server, conn)
+	}
+}
+
+// listen creates the socket accessible only by the current user
+func listen(socketPath string) (net.Listener, error) {
+	oldMask := syscall.Umask(0177)
+	lis, err := net.Listen("unix", socketPath)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
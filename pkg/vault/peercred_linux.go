@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package vault
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+const peerCredSupported = true
+
+func getPeerCred(conn net.Conn) (*peerCred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("Not a Unix socket connection")
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	peer := &peerCred{
+		UID: int(ucred.Uid),
+		PID: int(ucred.Pid),
+	}
+	peer.Executable, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", peer.PID))
+	return peer, nil
+}
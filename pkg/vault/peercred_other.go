@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package vault
+
+import (
+	"net"
+)
+
+const peerCredSupported = false
+
+// getPeerCred is only supported on Linux, elsewhere the server relies on the socket file permissions
+func getPeerCred(conn net.Conn) (*peerCred, error) {
+	return nil, errPeerCredUnsupported
+}
@@ -0,0 +1,148 @@
+package vault
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nordcloud/mfacli/config"
+)
+
+const (
+	// ProtocolVersion is increased whenever the server calls change in an incompatible way
+	ProtocolVersion = 2
+
+	// handshakeTimeout limits waiting for the reply of a server which doesn't understand the request
+	handshakeTimeout = 2 * time.Second
+)
+
+var (
+	// capabilities lists the methods of the server, so that the clients can check what's supported
+	capabilities = []string{
+		"Hello",
+		"ListClients",
+		"GenerateCode",
+		"GetSecrets",
+		"StoreSecrets",
+		"ApplyChanges",
+		"ChangeKey",
+		"Lock",
+		"Unlock",
+		"Status",
+		"Stop",
+	}
+
+	ErrIncompatibleServer = fmt.Errorf("Incompatible server is running, restart it with mfacli stop-server")
+	ErrIncompatibleClient = fmt.Errorf("Incompatible client, the server speaks JSON-RPC protocol version %d", ProtocolVersion)
+)
+
+type HelloReply struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	ServerVersion   string   `json:"server_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// Hello is the first call made by the clients, it doesn't require the vault to be unlocked
+func (s *VaultServer) Hello(input struct{}, reply *HelloReply) error {
+	*reply = HelloReply{
+		ProtocolVersion: ProtocolVersion,
+		ServerVersion:   config.Version,
+		Capabilities:    capabilities,
+	}
+	return nil
+}
+
+// bufferedConn lets the first bytes of the connection be peeked before it's handed to the codec
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// serveConn serves JSON-RPC requests on the connection. The clients of older versions speaking Go's gob
+// encoding are told to restart the server instead.
+func serveConn(server *rpc.Server, conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	buffered := &bufferedConn{Conn: conn, reader: reader}
+	if !strings.ContainsRune("{[ \t\r\n", rune(first[0])) {
+		rejectGobClient(buffered)
+		return
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(buffered))
+}
+
+func rejectGobClient(conn *bufferedConn) {
+	defer conn.Close()
+
+	decoder := gob.NewDecoder(conn)
+	encoder := gob.NewEncoder(conn)
+
+	var request rpc.Request
+	if err := decoder.Decode(&request); err != nil {
+		return
+	}
+	// the arguments of the request are discarded
+	if err := decoder.DecodeValue(reflect.Value{}); err != nil {
+		return
+	}
+
+	log.WithField("method", request.ServiceMethod).Warn("Rejected a request of an incompatible client")
+	response := rpc.Response{
+		ServiceMethod: request.ServiceMethod,
+		Seq:           request.Seq,
+		Error:         ErrIncompatibleClient.Error(),
+	}
+	if err := encoder.Encode(&response); err != nil {
+		return
+	}
+	encoder.Encode(struct{}{})
+}
+
+// dial connects to the server and checks whether it speaks the same protocol version
+func dial(socketPath string) (*rpc.Client, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client := jsonrpc.NewClient(conn)
+	var hello HelloReply
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := client.Call(serverName+".Hello", struct{}{}, &hello); err != nil {
+		log.WithError(err).Debug("Handshake with the server failed")
+		client.Close()
+		return nil, ErrIncompatibleServer
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if hello.ProtocolVersion != ProtocolVersion {
+		log.WithField("protocol_version", hello.ProtocolVersion).WithField("server_version", hello.ServerVersion).Debug("Server speaks another protocol version")
+		client.Close()
+		return nil, ErrIncompatibleServer
+	}
+
+	return client, nil
+}
@@ -1,10 +1,13 @@
 package vault
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net"
 	"net/rpc"
 	"os"
 	"os/exec"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -12,38 +15,86 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/codec"
+	"github.com/nordcloud/mfacli/pkg/password"
+	"github.com/nordcloud/mfacli/pkg/secret"
 )
 
 type remoteVault struct {
 	client *rpc.Client
+	cfg    *config.Config
+	// key is set once the password has been verified by the server
+	key *codec.Key
 }
 
-func (v *remoteVault) GetSecrets() (map[string]string, error) {
-	var secrets map[string]string
-	if err := v.client.Call(serverName+".GetSecrets", struct{}{}, &secrets); err != nil {
+func (v *remoteVault) ListClients() ([]string, error) {
+	var clientIDs []string
+	if err := v.call("ListClients", struct{}{}, &clientIDs); err != nil {
 		return nil, err
 	}
 
-	return secrets, nil
+	return clientIDs, nil
 }
 
-func (v *remoteVault) ModifySecrets(modify func(map[string]string) error) error {
-	secrets, err := v.GetSecrets()
+// GetSecrets asks for the vault password, as the server doesn't hand out the raw secrets without it
+func (v *remoteVault) GetSecrets() (map[string]secret.Entry, error) {
+	var snapshot Snapshot
+	err := v.callWithKey(func(key []byte) error {
+		return v.call("GetSecrets", GetSecretsArgs{Key: key}, &snapshot)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := modify(secrets); err != nil {
-		return err
+	return snapshot.Secrets, nil
+}
+
+func (v *remoteVault) ApplyChanges(changes ...Change) error {
+	return v.callWithKey(func(key []byte) error {
+		return v.call("ApplyChanges", ApplyChangesArgs{Key: key, Changes: changes}, nil)
+	})
+}
+
+// callWithKey passes the vault key to the call, asking for the password first if it hasn't been verified yet
+func (v *remoteVault) callWithKey(call func(key []byte) error) error {
+	if v.key != nil {
+		return call(v.key.Value)
 	}
 
-	if err := v.client.Call(serverName+".StoreSecrets", secrets, nil); err != nil {
+	key, err := promptKey(v.cfg, "Password", func(key *codec.Key) error {
+		return call(key.Value)
+	})
+	if err != nil {
 		return err
 	}
 
+	v.key = key
 	return nil
 }
 
+func (v *remoteVault) GenerateCode(clientID string, next bool) (Code, error) {
+	var code Code
+	args := GenerateCodeArgs{ClientID: clientID, Next: next}
+	if err := v.call("GenerateCode", args, &code); err != nil {
+		return Code{}, err
+	}
+
+	return code, nil
+}
+
+// call calls the server method, unlocking the server first if it's locked
+func (v *remoteVault) call(method string, args interface{}, reply interface{}) error {
+	err := remoteError(v.client.Call(serverName+"."+method, args, reply))
+	if err != ErrLocked {
+		return err
+	}
+
+	if err := unlock(v.cfg, v.client); err != nil {
+		return err
+	}
+	return remoteError(v.client.Call(serverName+"."+method, args, reply))
+}
+
 func StartServer(cfg *config.Config) error {
 	vault, err := openRemote(cfg)
 	if err != nil {
@@ -56,27 +107,154 @@ func StartServer(cfg *config.Config) error {
 	return nil
 }
 
+// ChangePassword re-encrypts the vault with a key derived from a new password.
+// If the cache server is running and unlocked it re-encrypts the vault itself and keeps using the new key.
+func ChangePassword(cfg *config.Config) error {
+	vault, err := readVaultFile(cfg, "Current password")
+	if err != nil {
+		return err
+	}
+
+	pwd, err := password.CreatePassword(cfg)
+	if err != nil {
+		return err
+	}
+	key, err := NewEncKey(cfg, pwd)
+	if err != nil {
+		return err
+	}
+
+	client, err := connect(cfg)
+	if err == ErrIncompatibleServer {
+		return err
+	}
+	if err != nil {
+		log.WithError(err).Debug("Server is not running, re-encrypting the vault locally")
+		return vault.changeKey(key)
+	}
+	defer client.Close()
+
+	args := ChangeKeyArgs{OldKey: vault.encKey.Value, NewKey: *key}
+	err = remoteError(client.Call(serverName+".ChangeKey", args, nil))
+	if err == ErrLocked {
+		// a locked server holds no key, it reads the vault with the new one once unlocked
+		log.Debug("Server is locked, re-encrypting the vault locally")
+		return vault.changeKey(key)
+	}
+	return err
+}
+
+// GetServerStatus returns the status of the running server without starting it
+func GetServerStatus(cfg *config.Config) (*ServerStatus, error) {
+	client, err := connectRunning(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var status ServerStatus
+	if err := client.Call(serverName+".Status", struct{}{}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func LockServer(cfg *config.Config) error {
+	client, err := connectRunning(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return remoteError(client.Call(serverName+".Lock", struct{}{}, nil))
+}
+
+func UnlockServer(cfg *config.Config) error {
+	client, err := connectRunning(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return unlock(cfg, client)
+}
+
+func unlock(cfg *config.Config, client *rpc.Client) error {
+	_, err := promptKey(cfg, "Password", func(key *codec.Key) error {
+		return remoteError(client.Call(serverName+".Unlock", key, nil))
+	})
+	return err
+}
+
+// promptKey asks for the password until the key derived from it is accepted by the try function
+func promptKey(cfg *config.Config, prompt string, try func(*codec.Key) error) (*codec.Key, error) {
+	data, err := ioutil.ReadFile(cfg.VaultPath)
+	if err != nil {
+		return nil, err
+	}
+	params, err := codec.ReadKDFParams(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		pwd, err := password.ReadPassword(cfg, prompt)
+		if err != nil {
+			return nil, err
+		}
+		key, err := codec.BuildEncKey(pwd, params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = try(key)
+		if err == codec.ErrInvalidPassword {
+			prompt = "Invalid password. Try again"
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return key, nil
+	}
+}
+
 func StopServer(cfg *config.Config) {
-	client, _ := connect(cfg)
-	if client != nil {
+	client, err := connect(cfg)
+	if err == ErrIncompatibleServer {
+		// servers of the older versions speak the gob encoding
+		client, err = rpc.Dial("unix", cfg.SocketPath)
+	}
+	if err == nil {
 		client.Call(serverName+".Stop", struct{}{}, nil)
+		client.Close()
 	}
 }
 
 func openRemote(cfg *config.Config) (*remoteVault, error) {
 	client, err := connect(cfg)
 	if err == nil {
-		return &remoteVault{client: client}, nil
+		return &remoteVault{client: client, cfg: cfg}, nil
 	}
 
 	if err := handleConnectError(err, cfg.SocketPath); err != nil {
 		return nil, err
 	}
+	if err := ValidateServerConfig(cfg); err != nil {
+		return nil, err
+	}
 
 	vault, err := openLocal(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if vault.upgraded {
+		// the server is only handed the new key, so the vault must be re-encrypted with it first
+		if err := vault.save(); err != nil {
+			return nil, err
+		}
+	}
 	if err := startServer(cfg, vault.encKey); err != nil {
 		return nil, err
 	}
@@ -92,11 +270,37 @@ func openRemote(cfg *config.Config) (*remoteVault, error) {
 		return nil, err
 	}
 
-	return &remoteVault{client: client}, nil
+	return &remoteVault{client: client, cfg: cfg}, nil
+}
+
+// remoteError translates the errors returned by the server back to the known error values
+func remoteError(err error) error {
+	if _, ok := err.(rpc.ServerError); !ok {
+		return err
+	}
+
+	for _, known := range []error{ErrClientNotFound, ErrLocked, ErrVaultChanged, ErrClientExists, ErrExecutableNotAllowed, codec.ErrInvalidPassword} {
+		if err.Error() == known.Error() {
+			return known
+		}
+	}
+	return err
 }
 
 func connect(cfg *config.Config) (*rpc.Client, error) {
-	return rpc.Dial("unix", cfg.SocketPath)
+	return dial(cfg.SocketPath)
+}
+
+// connectRunning connects to the server without starting it
+func connectRunning(cfg *config.Config) (*rpc.Client, error) {
+	client, err := connect(cfg)
+	if err == ErrIncompatibleServer {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrapf(ErrServerNotRunning, "connecting to %s", cfg.SocketPath)
+	}
+	return client, nil
 }
 
 func handleConnectError(connErr error, sockPath string) error {
@@ -136,16 +340,9 @@ func handleConnectError(connErr error, sockPath string) error {
 	return connErr
 }
 
-func startServer(cfg *config.Config, key []byte) error {
+func startServer(cfg *config.Config, key *codec.Key) error {
 	progname := getExecutableName()
-	args := []string{
-		config.InternalRunServerCmd,
-		"--socket", cfg.SocketPath,
-		"--vault", cfg.VaultPath,
-	}
-	if cfg.ServerLogFile != "" {
-		args = append(args, "--"+config.FlagServerLogFile, cfg.ServerLogFile)
-	}
+	args := append([]string{config.InternalRunServerCmd}, ServerArgs(cfg)...)
 	cmd := exec.Command(progname, args...)
 
 	pipe, err := cmd.StdinPipe()
@@ -158,7 +355,7 @@ func startServer(cfg *config.Config, key []byte) error {
 		return err
 	}
 
-	_, err = pipe.Write(key)
+	err = json.NewEncoder(pipe).Encode(key)
 	if err != nil {
 		return err
 	}
@@ -170,6 +367,28 @@ func startServer(cfg *config.Config, key []byte) error {
 	return nil
 }
 
+// ServerArgs returns the arguments passing the server options to the server process
+func ServerArgs(cfg *config.Config) []string {
+	args := []string{
+		"--socket", cfg.SocketPath,
+		"--vault", cfg.VaultPath,
+		"--" + config.FlagIdleTimeout, cfg.IdleTimeout.String(),
+		"--" + config.FlagMaxLifetime, cfg.MaxLifetime.String(),
+		"--" + config.FlagAuditLog, cfg.AuditLog,
+		"--" + config.FlagAuditLogMaxSize, strconv.FormatUint(uint64(cfg.AuditLogMaxSize), 10),
+	}
+	if cfg.ServerLogFile != "" {
+		args = append(args, "--"+config.FlagServerLogFile, cfg.ServerLogFile)
+	}
+	if cfg.LockOnScreensaver {
+		args = append(args, "--"+config.FlagLockOnScreensaver)
+	}
+	for _, executable := range cfg.AllowedExecutables {
+		args = append(args, "--"+config.FlagAllowedExecutable, executable)
+	}
+	return args
+}
+
 func getExecutableName() string {
 	path, err := os.Readlink("/proc/self/exe")
 	if err == nil {
@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dbusMonitorCmd = "dbus-monitor"
+)
+
+var (
+	screensaverMatchRules = []string{
+		"type='signal',interface='org.freedesktop.ScreenSaver',member='ActiveChanged'",
+		"type='signal',interface='org.gnome.ScreenSaver',member='ActiveChanged'",
+	}
+)
+
+// watchScreensaver locks the vault whenever the screensaver of the desktop session activates. It monitors
+// the session D-Bus for the ActiveChanged signal which is followed by a "boolean true" line when activated.
+func (s *VaultServer) watchScreensaver() {
+	args := append([]string{"--session"}, screensaverMatchRules...)
+	cmd := exec.Command(dbusMonitorCmd, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		log.WithError(err).Error("Failed to watch the screensaver")
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		log.WithError(err).Error("Failed to start " + dbusMonitorCmd)
+		return
+	}
+
+	activeChanged := false
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "member=ActiveChanged") {
+			activeChanged = true
+			continue
+		}
+
+		if activeChanged && line == "boolean true" {
+			log.Info("Screensaver activated, locking the vault")
+			s.mu.Lock()
+			s.lockVault()
+			s.mu.Unlock()
+		}
+		activeChanged = false
+	}
+
+	log.WithError(cmd.Wait()).Warn(dbusMonitorCmd + " exited, not watching the screensaver anymore")
+}
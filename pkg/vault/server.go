@@ -1,18 +1,23 @@
 package vault
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
 	"io/ioutil"
 	"net"
-	"net/rpc"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/audit"
 	"github.com/nordcloud/mfacli/pkg/codec"
+	"github.com/nordcloud/mfacli/pkg/secret"
 )
 
 const (
@@ -22,65 +27,376 @@ const (
 type VaultServer struct {
 	vault *localVault
 	lis   net.Listener
+	mu    sync.Mutex
+	cfg   *config.Config
+
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+
+	allowedExecutables map[string]bool
+	auditLog           *audit.Logger
+
+	startTime   time.Time
+	lastRequest time.Time
+	requests    uint64
 }
 
-func (s *VaultServer) GetSecrets(input struct{}, secrets *map[string]string) error {
-	*secrets = s.vault.secrets
-	return nil
+type ServerStatus struct {
+	PID        int       `json:"pid"`
+	SocketPath string    `json:"socket_path"`
+	VaultPath  string    `json:"vault_path"`
+	StartTime  time.Time `json:"start_time"`
+	ExpiresAt  time.Time `json:"expires_at"` // zero if the server never expires
+	Entries    int       `json:"entries"`
+	Requests   uint64    `json:"requests"`
+	Locked     bool      `json:"locked"`
 }
 
-func (s *VaultServer) StoreSecrets(secrets map[string]string, output *struct{}) error {
-	s.vault.secrets = secrets
-	return s.vault.save()
+// GenerateCodeArgs doesn't let the client choose the time, the codes are always generated with the server clock
+// so that no codes valid further than the next TOTP period can be obtained from the server
+type GenerateCodeArgs struct {
+	ClientID string `json:"client_id"`
+	// Next requests the TOTP code of the period following the current one, it's ignored for HOTP clients
+	Next bool `json:"next,omitempty"`
 }
 
-func (s *VaultServer) Stop(input struct{}, output *struct{}) error {
-	s.lis.Close()
-	return nil
+// The RPCs giving access to the raw secrets or the key require the vault key derived from the password,
+// so that a process able to connect to the socket cannot steal them
+type GetSecretsArgs struct {
+	Key []byte `json:"key"`
 }
 
-func RunServer(cfg *config.Config) error {
-	key, err := ioutil.ReadAll(os.Stdin)
-	if err != nil {
+// Snapshot is the content of the vault at the time it has been read. StoreSecrets is refused unless
+// the passed Revision is still the current one, so that newer changes are never overwritten.
+type Snapshot struct {
+	Secrets  map[string]secret.Entry `json:"secrets"`
+	Revision uint64                  `json:"revision"`
+}
+
+type StoreSecretsArgs struct {
+	Key      []byte                  `json:"key"`
+	Secrets  map[string]secret.Entry `json:"secrets"`
+	Revision uint64                  `json:"revision"`
+}
+
+type ApplyChangesArgs struct {
+	Key     []byte   `json:"key"`
+	Changes []Change `json:"changes"`
+}
+
+type ChangeKeyArgs struct {
+	OldKey []byte    `json:"old_key"`
+	NewKey codec.Key `json:"new_key"`
+}
+
+func (s *VaultServer) ListClients(input struct{}, clientIDs *[]string) error {
+	if err := s.beginRequest(); err != nil {
 		return err
 	}
-	vault, err := openLocalWithKey(cfg.VaultPath, key)
+	defer s.mu.Unlock()
+
+	var err error
+	*clientIDs, err = s.vault.ListClients()
+	return err
+}
+
+func (s *VaultServer) GetSecrets(args GetSecretsArgs, snapshot *Snapshot) error {
+	if err := s.beginRequest(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if err := s.checkKey(args.Key); err != nil {
+		return err
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	secrets, err := s.vault.GetSecrets()
 	if err != nil {
 		return err
 	}
+	*snapshot = Snapshot{Secrets: secrets, Revision: s.vault.revision}
+	return nil
+}
+
+// StoreSecrets replaces the whole set of secrets. mfacli itself sends the individual changes with ApplyChanges,
+// this method is part of the protocol for the other clients of the server.
+func (s *VaultServer) StoreSecrets(args StoreSecretsArgs, output *struct{}) error {
+	if err := s.beginRequest(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if err := s.checkKey(args.Key); err != nil {
+		return err
+	}
+	// the file might have been replaced before the change has been noticed by the watcher
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if args.Revision != s.vault.revision {
+		log.Warn("Refused to store secrets based on an outdated snapshot of the vault")
+		return ErrVaultChanged
+	}
+
+	return s.vault.replaceSecrets(args.Secrets, args.Revision)
+}
+
+func (s *VaultServer) ApplyChanges(args ApplyChangesArgs, output *struct{}) error {
+	if err := s.beginRequest(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if err := s.checkKey(args.Key); err != nil {
+		return err
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	return s.vault.ApplyChanges(args.Changes...)
+}
+
+func (s *VaultServer) GenerateCode(args GenerateCodeArgs, code *Code) error {
+	if err := s.beginRequest(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	var err error
+	*code, err = s.vault.GenerateCode(args.ClientID, args.Next)
+	return err
+}
+
+func (s *VaultServer) ChangeKey(args ChangeKeyArgs, output *struct{}) error {
+	if err := s.beginRequest(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if err := s.checkKey(args.OldKey); err != nil {
+		return err
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	log.Info("Re-encrypting the vault with a new key")
+	return s.vault.changeKey(&args.NewKey)
+}
+
+// beginRequest locks the server state and resets the idle timer, it's called at the beginning of each request.
+// If the vault is locked the state is unlocked again and ErrLocked is returned.
+func (s *VaultServer) beginRequest() error {
+	s.mu.Lock()
+	s.requests++
+	s.lastRequest = time.Now()
+	if s.idleTimer != nil {
+		s.idleTimer.Reset(s.idleTimeout)
+	}
+
+	if s.vault == nil {
+		s.mu.Unlock()
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s *VaultServer) expiresAt() time.Time {
+	var result time.Time
+	if s.cfg.MaxLifetime > 0 {
+		result = s.startTime.Add(s.cfg.MaxLifetime)
+	}
+	if s.idleTimeout > 0 {
+		if idleExpiry := s.lastRequest.Add(s.idleTimeout); result.IsZero() || idleExpiry.Before(result) {
+			result = idleExpiry
+		}
+	}
+	return result
+}
 
-	lis, err := net.Listen("unix", cfg.SocketPath)
+// reload decrypts the vault file again if it has been changed by another process. If the file cannot be
+// decrypted with the current key anymore (e.g. the password has been changed) the vault is locked.
+func (s *VaultServer) reload() error {
+	changed, err := s.vault.refresh()
+	if err == ErrVaultChanged {
+		s.lockVault()
+		return ErrLocked
+	}
 	if err != nil {
 		return err
 	}
 
-	defer lis.Close()
-	go handleSignals(lis)
+	if changed {
+		log.WithField("entries", len(s.vault.secrets)).Info("Vault file has changed, reloaded the secrets")
+	}
+	return nil
+}
 
-	err = rpc.Register(&VaultServer{
-		lis:   lis,
-		vault: vault,
-	})
+func (s *VaultServer) checkKey(key []byte) error {
+	if !hmac.Equal(key, s.vault.encKey.Value) {
+		log.Warn("Rejected a request with an invalid key")
+		return codec.ErrInvalidPassword
+	}
+	return nil
+}
+
+// Lock wipes the secrets and the key from the memory, the server keeps running until it's unlocked again
+func (s *VaultServer) Lock(input struct{}, output *struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lockVault()
+	return nil
+}
+
+func (s *VaultServer) Unlock(key codec.Key, output *struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault != nil {
+		return s.checkKey(key.Value)
+	}
+
+	vault, err := openLocalWithKey(s.cfg.VaultPath, &key)
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		time.Sleep(8 * time.Hour)
-		log.Info("closing listener after timeout")
-		lis.Close()
-	}()
+	log.Info("Unlocking the vault")
+	s.vault = vault
+	return nil
+}
+
+func (s *VaultServer) lockVault() {
+	if s.vault == nil {
+		return
+	}
+
+	log.Info("Locking the vault")
+	for i := range s.vault.encKey.Value {
+		s.vault.encKey.Value[i] = 0
+	}
+	for clientID := range s.vault.secrets {
+		delete(s.vault.secrets, clientID)
+	}
+	s.vault = nil
+}
+
+// Status doesn't reset the idle timer so that polling it doesn't keep the server running
+func (s *VaultServer) Status(input struct{}, status *ServerStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	rpc.Accept(lis)
+	*status = ServerStatus{
+		PID:        os.Getpid(),
+		SocketPath: s.cfg.SocketPath,
+		VaultPath:  s.cfg.VaultPath,
+		StartTime:  s.startTime,
+		ExpiresAt:  s.expiresAt(),
+		Requests:   s.requests,
+		Locked:     s.vault == nil,
+	}
+	if s.vault != nil {
+		status.Entries = len(s.vault.secrets)
+	}
 	return nil
 }
 
-func openLocalWithKey(path string, key []byte) (*localVault, error) {
-	file, err := os.Open(path)
+func (s *VaultServer) Stop(input struct{}, output *struct{}) error {
+	s.lis.Close()
+	return nil
+}
+
+// RunServer serves the vault on the socket. When it's socket-activated by systemd the server starts locked
+// and waits for the Unlock call, otherwise the vault key is read from the standard input.
+func RunServer(cfg *config.Config) error {
+	if err := ValidateServerConfig(cfg); err != nil {
+		return err
+	}
+
+	lis, err := activationListener()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	var vault *localVault
+	if lis != nil {
+		log.WithField("socket_path", cfg.SocketPath).Info("Using the socket passed by systemd, starting locked")
+	} else {
+		var key codec.Key
+		if err := json.NewDecoder(os.Stdin).Decode(&key); err != nil {
+			return err
+		}
+		vault, err = openLocalWithKey(cfg.VaultPath, &key)
+		if err != nil {
+			return err
+		}
+
+		lis, err = listen(cfg.SocketPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer lis.Close()
+
+	now := time.Now()
+	server := &VaultServer{
+		lis:         lis,
+		vault:       vault,
+		cfg:         cfg,
+		idleTimeout: cfg.IdleTimeout,
+		startTime:   now,
+		lastRequest: now,
 	}
-	data, err := ioutil.ReadAll(file)
+	if len(cfg.AllowedExecutables) > 0 {
+		server.allowedExecutables = map[string]bool{getExecutableName(): true}
+		for _, executable := range cfg.AllowedExecutables {
+			server.allowedExecutables[executable] = true
+		}
+	}
+
+	if cfg.AuditLog != "" {
+		server.auditLog, err = audit.NewLogger(cfg.AuditLog, int64(cfg.AuditLogMaxSize)*1024*1024)
+		if err != nil {
+			return err
+		}
+		defer server.auditLog.Close()
+	}
+
+	go server.handleSignals()
+	go server.watchVaultFile()
+	if cfg.LockOnScreensaver {
+		go server.watchScreensaver()
+	}
+
+	if cfg.IdleTimeout > 0 {
+		server.idleTimer = time.AfterFunc(cfg.IdleTimeout, func() {
+			log.Info("closing listener after idle timeout")
+			lis.Close()
+		})
+	}
+	if cfg.MaxLifetime > 0 {
+		time.AfterFunc(cfg.MaxLifetime, func() {
+			log.Info("closing listener after max lifetime")
+			lis.Close()
+		})
+	}
+
+	server.accept()
+	return nil
+}
+
+func openLocalWithKey(path string, key *codec.Key) (*localVault, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -93,14 +409,24 @@ func openLocalWithKey(path string, key []byte) (*localVault, error) {
 		secrets: secrets,
 		encKey:  key,
 		path:    path,
+		digest:  sha256.Sum256(data),
 	}, nil
 }
 
-func handleSignals(lis net.Listener) {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	s := <-c
-	log.Infof("Caught the %s signal, closing server", s.String())
-	lis.Close()
-	os.Exit(0)
+func (s *VaultServer) handleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGUSR1)
+	for sig := range c {
+		if sig == syscall.SIGUSR1 {
+			log.Info("Caught the SIGUSR1 signal, locking the vault")
+			s.mu.Lock()
+			s.lockVault()
+			s.mu.Unlock()
+			continue
+		}
+
+		log.Infof("Caught the %s signal, closing server", sig.String())
+		s.lis.Close()
+		os.Exit(0)
+	}
 }
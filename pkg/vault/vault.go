@@ -2,22 +2,42 @@ package vault
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/nordcloud/mfacli/config"
+	"github.com/nordcloud/mfacli/pkg/secret"
 )
 
 type Vault interface {
-	GetSecrets() (map[string]string, error)
-	ModifySecrets(func(map[string]string) error) error
+	// ListClients returns the sorted client IDs
+	ListClients() ([]string, error)
+	GetSecrets() (map[string]secret.Entry, error)
+	// ApplyChanges applies all the changes or none of them
+	ApplyChanges(changes ...Change) error
+	// GenerateCode returns the current code for the client, or the TOTP code of the next period if next is set.
+	// The counter of an HOTP client is advanced and stored before the code is returned, so a code is never
+	// generated twice.
+	GenerateCode(clientID string, next bool) (Code, error)
+}
+
+type Code struct {
+	Value string `json:"code"`
+	// ExpiresAt is the end of the TOTP period, it's zero for HOTP codes
+	ExpiresAt time.Time `json:"expires_at"`
+	// Period is the length of the TOTP period in seconds, it's zero for HOTP codes
+	Period uint `json:"period,omitempty"`
 }
 
 type CobraFn func(*cobra.Command, []string) error
 type VaultFn func(Vault, ...string) error
 
 var (
-	ErrClientNotFound = fmt.Errorf("Client ID not found")
+	ErrClientNotFound   = fmt.Errorf("Client ID not found")
+	ErrServerNotRunning = fmt.Errorf("Server is not running")
+	ErrLocked           = fmt.Errorf("Server is locked, run mfacli unlock")
+	ErrVaultChanged     = fmt.Errorf("The vault has been changed by another process, try again")
 )
 
 func Open(cfg *config.Config) (Vault, error) {
@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package vault
+
+import (
+	"bytes"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchVaultFile reloads the vault whenever the vault file is written or replaced. The directory is watched
+// rather than the file itself, because the file is replaced by a rename on every save.
+func (s *VaultServer) watchVaultFile() {
+	dir, name := filepath.Split(s.cfg.VaultPath)
+	if dir == "" {
+		dir = "."
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		log.WithError(err).Error("Failed to watch the vault file")
+		return
+	}
+	defer syscall.Close(fd)
+
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		log.WithError(err).WithField("dir", dir).Error("Failed to watch the vault directory")
+		return
+	}
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to read inotify events, not watching the vault file anymore")
+			return
+		}
+
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+
+			eventName := string(bytes.TrimRight(buf[nameStart:offset], "\x00"))
+			if eventName == name {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.vault != nil {
+			if err := s.reload(); err != nil {
+				log.WithError(err).Warn("Failed to reload the vault")
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package vault
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// watchVaultFile is only supported on Linux, elsewhere the changes are picked up when the vault is accessed
+func (s *VaultServer) watchVaultFile() {
+	log.Debug("Watching the vault file is not supported on this platform")
+}